@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	tdserver "td_go_mcp/internal/server"
+
+	"golang.org/x/exp/slog"
+)
+
+// adminPingTimeout bounds each database ping made by the admin endpoint
+const adminPingTimeout = 3 * time.Second
+
+// registry records what was actually registered with the MCP server, so the
+// admin endpoint reports this process's state rather than re-reading tools/
+type registry struct {
+	mu        sync.Mutex
+	startedAt time.Time
+	tools     []tdserver.Component
+	prompts   []tdserver.Component
+}
+
+var registered = &registry{startedAt: time.Now().UTC()}
+
+func (r *registry) addTool(name, source string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tools = append(r.tools, tdserver.Component{Name: name, Source: source})
+}
+
+func (r *registry) addPrompt(name, source string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.prompts = append(r.prompts, tdserver.Component{Name: name, Source: source})
+}
+
+// Status implements tdserver.StatusProvider
+func (r *registry) Status(ctx context.Context) tdserver.ServerInfo {
+	r.mu.Lock()
+	info := tdserver.ServerInfo{
+		Name:       "td-go-mcp",
+		Version:    tdserver.Version,
+		Status:     "ok",
+		Tools:      len(r.tools),
+		Prompts:    len(r.prompts),
+		StartedAt:  r.startedAt,
+		Build:      tdserver.ReadBuildInfo(),
+		ToolList:   append([]tdserver.Component(nil), r.tools...),
+		PromptList: append([]tdserver.Component(nil), r.prompts...),
+		LoadErrors: loadErrors,
+	}
+	r.mu.Unlock()
+
+	info.Databases = []tdserver.DatabaseStatus{databaseStatus(ctx)}
+	if len(info.LoadErrors) > 0 || info.Databases[0].Status != "connected" {
+		info.Status = "degraded"
+	}
+	return info
+}
+
+// Ready implements tdserver.StatusProvider: the registry must have loaded
+// every YAML file and each configured connection must answer a ping
+func (r *registry) Ready(ctx context.Context) []tdserver.Check {
+	checks := []tdserver.Check{{Component: "registry", OK: len(loadErrors) == 0}}
+	if len(loadErrors) > 0 {
+		checks[0].Error = fmt.Sprintf("%d YAML file(s) failed to load, first: %s: %s", len(loadErrors), loadErrors[0].File, loadErrors[0].Error)
+	}
+
+	db := databaseStatus(ctx)
+	check := tdserver.Check{Component: "database:" + db.Name, OK: db.Status == "connected", Error: db.Error}
+	if !check.OK && check.Error == "" {
+		check.Error = db.Status
+	}
+	return append(checks, check)
+}
+
+// databaseStatus pings the configured connection
+func databaseStatus(ctx context.Context) tdserver.DatabaseStatus {
+	status := tdserver.DatabaseStatus{Name: dbConfig.ConnectionName(), Driver: dbConfig.Driver}
+	if database == nil {
+		status.Status = "not connected"
+		if dbConnectErr != nil {
+			status.Error = dbConnectErr.Error()
+		}
+		return status
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, adminPingTimeout)
+	defer cancel()
+	if err := database.PingContext(ctx); err != nil {
+		status.Status = "unreachable"
+		status.Error = err.Error()
+		return status
+	}
+	status.Status = "connected"
+	return status
+}
+
+// registerAdminRoutes mounts the admin endpoints, the REST tools API and its OpenAPI spec
+func registerAdminRoutes(mux *http.ServeMux) {
+	tdserver.RegisterRoutes(mux, registered)
+	tdserver.RegisterAPIRoutes(mux, apiRunner{})
+	mux.HandleFunc("GET /openapi.json", openAPIHandler)
+}
+
+// startAdminServer serves the admin endpoint on its own listener. It is used
+// in stdio mode, or in http mode when admin.listen differs from http.listen.
+func startAdminServer(addr string) *http.Server {
+	mux := http.NewServeMux()
+	registerAdminRoutes(mux)
+	srv := &http.Server{Addr: addr, Handler: protect(mux), ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		slog.Info("Admin endpoint listening", "addr", addr)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("Admin endpoint failed", "addr", addr, "err", err)
+		}
+	}()
+	return srv
+}
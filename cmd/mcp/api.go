@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"td_go_mcp/internal/audit"
+	"td_go_mcp/internal/db"
+	"td_go_mcp/internal/ratelimit"
+	tdserver "td_go_mcp/internal/server"
+	"td_go_mcp/internal/tools"
+)
+
+// apiRunner exposes the registered YAML tools to the REST API through the
+// same validation, rendering, execution and audit path as MCP tools/call
+type apiRunner struct{}
+
+// Tools implements tdserver.ToolRunner, listing the tools the caller may run
+func (apiRunner) Tools(ctx context.Context) []tdserver.APITool {
+	defs := authorizedAPITools(ctx)
+	result := make([]tdserver.APITool, 0, len(defs))
+	for _, toolDef := range defs {
+		result = append(result, tdserver.APITool{
+			Name:        toolDef.Name,
+			Description: toolDef.Description,
+			InputSchema: convertToolDefinition(toolDef).InputSchema,
+			Source:      toolDef.SourceFile,
+		})
+	}
+	return result
+}
+
+// RunTool implements tdserver.ToolRunner
+func (r apiRunner) RunTool(ctx context.Context, name string, args map[string]any) (*tdserver.ToolResult, error) {
+	toolDef, err := r.lookup(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	delete(args, "__preview")
+	release, err := admit(ctx, name)
+	if errors.Is(err, ratelimit.ErrLimited) {
+		return nil, fmt.Errorf("%w: %w", tdserver.ErrRateLimited, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	run, err := runYAMLTool(ctx, toolDef, args, nil)
+	if err != nil {
+		return nil, apiError(err)
+	}
+	if !run.NoDatabase {
+		result := &tdserver.ToolResult{Rows: nonNil(run.Rows), Count: len(run.Rows), SQL: run.SQL, Source: "database"}
+		result.Results = run.Results
+		result.Result = run.Output
+		if run.Cached {
+			result.Cached, result.CachedAt = true, &run.CachedAt
+		}
+		return result, nil
+	}
+	if run.TestDataErr != nil {
+		return nil, fmt.Errorf("%w: failed to load test data: %v", tdserver.ErrDatabaseUnavailable, run.TestDataErr)
+	}
+	rows, ok := testRows(run.TestData)
+	if !ok {
+		return nil, fmt.Errorf("%w: use the preview endpoint to see the generated SQL", tdserver.ErrDatabaseUnavailable)
+	}
+	return &tdserver.ToolResult{Rows: rows, Count: len(rows), SQL: run.SQL, Source: "test_message", Result: run.Output}, nil
+}
+
+// PreviewTool implements tdserver.ToolRunner
+func (r apiRunner) PreviewTool(ctx context.Context, name string, args map[string]any) (string, error) {
+	toolDef, err := r.lookup(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	args["__preview"] = true
+	run, err := runYAMLTool(ctx, toolDef, args, nil)
+	if err != nil {
+		return "", apiError(err)
+	}
+	return run.SQL, nil
+}
+
+// apiTools returns the YAML tools served by the REST API. connection_status
+// has a Go handler and is MCP-only.
+func apiTools() []tools.ToolDefinition {
+	result := make([]tools.ToolDefinition, 0, len(loadedTools))
+	for _, toolDef := range loadedTools {
+		if toolDef.Name != "connection_status" {
+			result = append(result, toolDef)
+		}
+	}
+	return result
+}
+
+// authorizedAPITools returns the API tools the caller in ctx may run
+func authorizedAPITools(ctx context.Context) []tools.ToolDefinition {
+	var result []tools.ToolDefinition
+	for _, toolDef := range apiTools() {
+		if authorize(ctx, toolDef.Name) == nil {
+			result = append(result, toolDef)
+		}
+	}
+	return result
+}
+
+// lookup finds a tool served by the API that the caller may run
+func (apiRunner) lookup(ctx context.Context, name string) (tools.ToolDefinition, error) {
+	for _, toolDef := range apiTools() {
+		if toolDef.Name == name {
+			if err := authorizeAndAudit(ctx, name); err != nil {
+				return tools.ToolDefinition{}, fmt.Errorf("%w: %v", tdserver.ErrForbidden, err)
+			}
+			return toolDef, nil
+		}
+	}
+	return tools.ToolDefinition{}, fmt.Errorf("%w: %s", tdserver.ErrToolNotFound, name)
+}
+
+// apiError maps a toolError onto the REST API's error kinds
+func apiError(err error) error {
+	var te *toolError
+	if !errors.As(err, &te) {
+		return err
+	}
+	switch {
+	case errors.Is(te.Err, db.ErrNoIdentity):
+		return fmt.Errorf("%w: %v", tdserver.ErrForbidden, te.Err)
+	case te.Database:
+		return fmt.Errorf("%w: %v", tdserver.ErrDatabase, te.Err)
+	case te.Outcome == audit.OutcomeRejected:
+		return fmt.Errorf("%w: %v", tdserver.ErrInvalidArguments, te.Err)
+	}
+	return err
+}
+
+// testRows converts a return_test_message payload to rows when it is a
+// JSON array of objects
+func testRows(data interface{}) ([]map[string]any, bool) {
+	items, ok := data.([]interface{})
+	if !ok {
+		return nil, false
+	}
+	rows := make([]map[string]any, 0, len(items))
+	for _, item := range items {
+		row, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		rows = append(rows, row)
+	}
+	return rows, true
+}
+
+func nonNil(rows []map[string]interface{}) []map[string]interface{} {
+	if rows == nil {
+		return []map[string]interface{}{}
+	}
+	return rows
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+
+	"td_go_mcp/internal/audit"
+	"td_go_mcp/internal/auth"
+
+	"github.com/mark3labs/mcp-go/server"
+	"golang.org/x/exp/slog"
+)
+
+// sessionInfo returns the MCP session id and client name for the current call
+func sessionInfo(ctx context.Context) (sessionID string, client string) {
+	session := server.ClientSessionFromContext(ctx)
+	if session == nil {
+		return "", ""
+	}
+	sessionID = session.SessionID()
+	if withInfo, ok := session.(server.SessionWithClientInfo); ok {
+		client = withInfo.GetClientInfo().Name
+	}
+	return sessionID, client
+}
+
+// connectionName returns the name of the active database connection
+func connectionName() string {
+	if database == nil {
+		return ""
+	}
+	return database.Name()
+}
+
+// queryBand tags the statements of a tool call so DBAs can attribute them
+// in DBQL. It names the authenticated caller when there is one.
+func queryBand(ctx context.Context, tool string) map[string]string {
+	band := map[string]string{"ApplicationName": "td-go-mcp", "Tool": tool}
+	if p := auth.PrincipalFromContext(ctx); p != nil {
+		band["ClientUser"] = p.Subject
+		band["AuthMethod"] = p.Method
+	}
+	return band
+}
+
+// recordAudit stamps rec with the caller's session and writes it to the
+// audit stream. The same record drives the tool call metrics.
+func recordAudit(ctx context.Context, rec audit.Record) {
+	observeToolCall(rec)
+	rec.SessionID, rec.Client = sessionInfo(ctx)
+	rec.Pipeline = pipelineFromContext(ctx)
+	if p := auth.PrincipalFromContext(ctx); p != nil {
+		rec.Principal, rec.AuthMethod = p.Subject, p.Method
+	}
+	if err := auditLog.Log(rec); err != nil {
+		slog.Error("Failed to write audit record", "tool", rec.Tool, "err", err)
+	}
+}
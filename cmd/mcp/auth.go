@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"td_go_mcp/internal/auth"
+	"td_go_mcp/internal/db"
+)
+
+// authenticator guards the HTTP listeners; nil when auth is disabled
+var authenticator *auth.Authenticator
+
+// protect wraps mux with authentication when it is enabled and serves the
+// OAuth protected-resource metadata that points clients at the authorization server
+func protect(mux *http.ServeMux) http.Handler {
+	if authenticator == nil {
+		return mux
+	}
+	mux.Handle("GET "+auth.MetadataPath, authenticator.MetadataHandler())
+	return authenticator.Middleware(callerIdentity(mux))
+}
+
+// callerIdentity runs the authenticated caller's queries under its own
+// database identity when the connection's identity mode asks for it
+func callerIdentity(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if p := auth.PrincipalFromContext(r.Context()); p != nil {
+			r = r.WithContext(withCaller(r.Context(), p))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// withCaller attaches p to ctx as the caller for authorization, auditing
+// and the database identity
+func withCaller(ctx context.Context, p *auth.Principal) context.Context {
+	return db.WithUser(auth.WithPrincipal(ctx, p), p.Subject)
+}
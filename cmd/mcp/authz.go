@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"td_go_mcp/internal/audit"
+	"td_go_mcp/internal/auth"
+	"td_go_mcp/internal/authz"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"golang.org/x/exp/slog"
+)
+
+// policy maps callers to roles and overrides tool rules; loaded in main
+var policy = &authz.Policy{}
+
+// toolRules holds each registered tool's allowed_roles/allowed_users
+var toolRules = struct {
+	sync.RWMutex
+	m map[string]authz.Rule
+}{m: map[string]authz.Rule{}}
+
+// guardTool records the access rule a tool was registered with
+func guardTool(name string, rule authz.Rule) {
+	toolRules.Lock()
+	defer toolRules.Unlock()
+	toolRules.m[name] = rule
+}
+
+// ruleFor returns the effective rule of a tool: the policy file's override,
+// or the rule from its definition
+func ruleFor(name string) authz.Rule {
+	toolRules.RLock()
+	defer toolRules.RUnlock()
+	return policy.RuleFor(name, toolRules.m[name])
+}
+
+// authorize checks that the caller in ctx may use tool
+func authorize(ctx context.Context, tool string) error {
+	return policy.Check(tool, ruleFor(tool), auth.PrincipalFromContext(ctx))
+}
+
+// authorizeAndAudit is authorize, recording denied calls in the audit stream
+func authorizeAndAudit(ctx context.Context, tool string) error {
+	err := authorize(ctx, tool)
+	if err != nil {
+		slog.WarnContext(ctx, "Tool call denied", "tool", tool, "err", err)
+		recordAudit(ctx, audit.Record{
+			Tool:       tool,
+			Connection: connectionName(),
+			Outcome:    audit.OutcomeRejected,
+			Error:      err.Error(),
+		})
+	}
+	return err
+}
+
+// authzMiddleware rejects tool calls the caller is not allowed to make
+func authzMiddleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if err := authorizeAndAudit(ctx, req.Params.Name); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		return next(ctx, req)
+	}
+}
+
+// toolFilter hides the tools the caller may not call from tools/list
+func toolFilter(ctx context.Context, listed []mcp.Tool) []mcp.Tool {
+	allowed := make([]mcp.Tool, 0, len(listed))
+	for _, tool := range listed {
+		if authorize(ctx, tool.Name) == nil {
+			allowed = append(allowed, tool)
+		}
+	}
+	return allowed
+}
+
+// stdioIdentity attaches the caller named by authz.identity to the stdio
+// session, which has no HTTP authentication to identify it. It is also the
+// database identity in proxy and credentials modes.
+func stdioIdentity(ctx context.Context) context.Context {
+	if appConfig.Authz.Identity == "" {
+		return ctx
+	}
+	return withCaller(ctx, &auth.Principal{
+		Subject: appConfig.Authz.Identity,
+		Roles:   appConfig.Authz.IdentityRoles,
+		Method:  auth.MethodEnv,
+	})
+}
+
+// loadPolicy reads the authorization policy file named in the config
+func loadPolicy() error {
+	p, err := authz.LoadPolicy(appConfig.Authz.PolicyFile)
+	if err != nil {
+		return fmt.Errorf("authorization policy: %w", err)
+	}
+	policy = p
+	return nil
+}
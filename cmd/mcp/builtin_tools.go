@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"td_go_mcp/internal/audit"
+	"td_go_mcp/internal/config"
+	"td_go_mcp/internal/db"
+	"td_go_mcp/internal/explain"
+	"td_go_mcp/internal/sqlguard"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"golang.org/x/exp/slog"
+)
+
+// Built-in tools are implemented in Go rather than loaded from tools/*.yaml
+
+func addBuiltinTools(mcpServer *server.MCPServer) {
+	explainTool := mcp.NewTool("explain_query",
+		mcp.WithDescription("Run Teradata EXPLAIN on a read-only SELECT and return the raw plan plus structured steps (operation, spool, estimated rows and time, confidence, join types)"),
+		mcp.WithString("sql", mcp.Required(), mcp.Description("The SELECT statement to explain")),
+	)
+	mcpServer.AddTool(explainTool, explainQueryHandler)
+	registered.addTool(explainTool.Name, "builtin")
+	slog.Info("Registered tool", "tool", explainTool.Name)
+
+	if appConfig.RunSQL.Enabled {
+		runSQLTool := mcp.NewTool("run_sql",
+			mcp.WithDescription(fmt.Sprintf("Run an ad-hoc read-only SELECT against the database. Statements are checked against read-only rules and database/table allowlists, limited to %d rows and %d seconds, and audited.", appConfig.RunSQL.MaxRows, appConfig.RunSQL.TimeoutSeconds)),
+			mcp.WithString("sql", mcp.Required(), mcp.Description("The SELECT statement to run")),
+		)
+		mcpServer.AddTool(runSQLTool, runSQLHandler)
+		registered.addTool(runSQLTool.Name, "builtin")
+		slog.Info("Registered tool", "tool", runSQLTool.Name)
+	}
+}
+
+func explainQueryHandler(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	slog.InfoContext(ctx, "Handling tool call", "tool", "explain_query")
+	start := time.Now()
+	sql := strings.TrimSuffix(strings.TrimSpace(req.GetString("sql", "")), ";")
+	var callErr error
+
+	defer func() {
+		args, auditSQL := redactBuiltinCall(req.GetArguments(), sql)
+		rec := audit.Record{
+			Tool:       "explain_query",
+			Arguments:  args,
+			SQL:        auditSQL,
+			Connection: connectionName(),
+			DurationMS: time.Since(start).Milliseconds(),
+			Outcome:    audit.OutcomeOK,
+		}
+		if callErr != nil {
+			rec.Outcome = audit.OutcomeError
+			rec.Error = callErr.Error()
+			if errors.Is(callErr, sqlguard.ErrNotReadOnly) {
+				rec.Outcome = audit.OutcomeRejected
+			}
+		}
+		recordAudit(ctx, rec)
+	}()
+
+	if sql == "" {
+		callErr = fmt.Errorf("missing required parameter: sql")
+		return mcp.NewToolResultError(fmt.Sprintf("parameter validation failed: %v", callErr)), nil
+	}
+	if callErr = sqlguard.CheckReadOnly(sql); callErr != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("query rejected: %v", callErr)), nil
+	}
+	if database == nil {
+		callErr = fmt.Errorf("database connection not available")
+		return mcp.NewToolResultError("Database connection not available. EXPLAIN requires a live connection."), nil
+	}
+
+	raw, callErr := database.Explain(ctx, sql)
+	if callErr != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("EXPLAIN failed: %v", callErr)), nil
+	}
+	plan := explain.Parse(raw)
+
+	resultJSON, err := json.Marshal(map[string]interface{}{
+		"sql":                          sql,
+		"raw":                          plan.Raw,
+		"steps":                        plan.Steps,
+		"total_estimated_time_seconds": plan.TotalEstimatedTime,
+	})
+	if err != nil {
+		callErr = err
+		return mcp.NewToolResultError(fmt.Sprintf("failed to marshal explain result: %v", err)), nil
+	}
+	return mcp.NewToolResultText(string(resultJSON)), nil
+}
+
+// redactBuiltinCall applies the audit redaction rules to a builtin tool call.
+// The sql argument is the statement itself, so it is recorded redacted too.
+func redactBuiltinCall(args map[string]any, sql string) (map[string]any, string) {
+	sql = audit.RedactSQL(sql, args, nil)
+	redacted := audit.RedactArguments(args, nil)
+	if _, ok := redacted["sql"]; ok {
+		redacted["sql"] = sql
+	}
+	return redacted, sql
+}
+
+func runSQLHandler(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	slog.InfoContext(ctx, "Handling tool call", "tool", "run_sql")
+	cfg := appConfig.RunSQL
+	start := time.Now()
+	sql := strings.TrimSuffix(strings.TrimSpace(req.GetString("sql", "")), ";")
+	var rowCount int
+	var callErr error
+
+	// Every call is recorded, whether it was rejected, failed or succeeded
+	defer func() {
+		args, auditSQL := redactBuiltinCall(req.GetArguments(), sql)
+		rec := audit.Record{
+			Tool:       "run_sql",
+			Arguments:  args,
+			SQL:        auditSQL,
+			Connection: connectionName(),
+			Rows:       rowCount,
+			DurationMS: time.Since(start).Milliseconds(),
+			Outcome:    audit.OutcomeOK,
+		}
+		if callErr != nil {
+			rec.Outcome = audit.OutcomeError
+			rec.Error = callErr.Error()
+			if errors.Is(callErr, sqlguard.ErrNotReadOnly) || errors.Is(callErr, sqlguard.ErrNotAllowed) || errors.Is(callErr, errCostExceeded) || errors.Is(callErr, db.ErrNoIdentity) {
+				rec.Outcome = audit.OutcomeRejected
+			}
+		}
+		recordAudit(ctx, rec)
+	}()
+
+	if sql == "" {
+		callErr = fmt.Errorf("missing required parameter: sql")
+		return mcp.NewToolResultError(fmt.Sprintf("parameter validation failed: %v", callErr)), nil
+	}
+	if callErr = sqlguard.CheckReadOnly(sql); callErr != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("query rejected: %v", callErr)), nil
+	}
+	refs, callErr := sqlguard.TableRefs(sql)
+	if callErr != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("query rejected: %v", callErr)), nil
+	}
+	allowlist := sqlguard.Allowlist{Databases: cfg.AllowedDatabases, Tables: cfg.AllowedTables}
+	if callErr = allowlist.Check(refs); callErr != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("query rejected: %v", callErr)), nil
+	}
+	if database == nil {
+		callErr = fmt.Errorf("database connection not available")
+		return mcp.NewToolResultError("Database connection not available. run_sql requires a live connection."), nil
+	}
+
+	progress := newProgressReporter(ctx, req)
+	if cfg.TimeoutSeconds > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(cfg.TimeoutSeconds)*time.Second)
+		defer cancel()
+	}
+
+	if cfg.MaxEstimatedSeconds > 0 || cfg.MaxEstimatedRows > 0 || cfg.DenyProductJoins {
+		progress.Phase("checking cost")
+		raw, err := database.Explain(ctx, sql)
+		if err != nil {
+			callErr = err
+			return mcp.NewToolResultError(fmt.Sprintf("cost check failed: %v", err)), nil
+		}
+		if callErr = checkCost(explain.Parse(raw), cfg); callErr != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("query rejected: %v", callErr)), nil
+		}
+	}
+
+	queryStart := time.Now()
+	rows, truncated, callErr := database.ExecuteQueryContext(ctx, sql, db.QueryOptions{MaxRows: cfg.MaxRows, Progress: progress.Query(), Heartbeat: progress.Heartbeat(), QueryBand: queryBand(ctx, "run_sql")})
+	if callErr != nil {
+		slog.ErrorContext(ctx, "SQL execution failed", "tool", "run_sql", "duration_ms", time.Since(queryStart).Milliseconds(), "err", callErr)
+		return mcp.NewToolResultError(fmt.Sprintf("SQL execution failed: %v", callErr)), nil
+	}
+	rowCount = len(rows)
+	progress.Phase(fmt.Sprintf("done: %d rows", rowCount))
+	slog.InfoContext(ctx, "SQL executed", "tool", "run_sql", "rows", rowCount, "truncated", truncated, "duration_ms", time.Since(queryStart).Milliseconds())
+
+	resultJSON, err := json.Marshal(map[string]interface{}{
+		"rows":      rows,
+		"count":     len(rows),
+		"truncated": truncated,
+		"tables":    refs,
+		"sql":       sql,
+	})
+	if err != nil {
+		callErr = err
+		return mcp.NewToolResultError(fmt.Sprintf("failed to marshal results: %v", err)), nil
+	}
+	return mcp.NewToolResultText(string(resultJSON)), nil
+}
+
+// errCostExceeded is returned when the optimizer's estimates exceed the configured limits
+var errCostExceeded = errors.New("estimated cost exceeds limits")
+
+func checkCost(plan explain.Plan, cfg config.RunSQLConfig) error {
+	if cfg.MaxEstimatedSeconds > 0 && plan.TotalEstimatedTime > cfg.MaxEstimatedSeconds {
+		return fmt.Errorf("%w: estimated time %.2fs exceeds %.2fs", errCostExceeded, plan.TotalEstimatedTime, cfg.MaxEstimatedSeconds)
+	}
+	for _, step := range plan.Steps {
+		if cfg.MaxEstimatedRows > 0 && step.EstimatedRows > cfg.MaxEstimatedRows {
+			return fmt.Errorf("%w: step %s estimates %d rows, limit is %d", errCostExceeded, step.Number, step.EstimatedRows, cfg.MaxEstimatedRows)
+		}
+		if cfg.DenyProductJoins && contains(step.JoinTypes, "product") {
+			return fmt.Errorf("%w: step %s uses a product join", errCostExceeded, step.Number)
+		}
+	}
+	return nil
+}
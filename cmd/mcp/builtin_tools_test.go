@@ -0,0 +1,38 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+
+	"td_go_mcp/internal/audit"
+	"td_go_mcp/internal/config"
+)
+
+func TestBuiltinToolsRedactAuditedArguments(t *testing.T) {
+	savedLog, savedDB, savedConfig := auditLog, database, appConfig
+	defer func() { auditLog, database, appConfig = savedLog, savedDB, savedConfig }()
+	var buf bytes.Buffer
+	auditLog, database, appConfig = audit.NewWriter(&buf), nil, &config.Config{}
+
+	for name, handler := range map[string]func(context.Context, mcp.CallToolRequest) (*mcp.CallToolResult, error){
+		"explain_query": explainQueryHandler,
+		"run_sql":       runSQLHandler,
+	} {
+		buf.Reset()
+		var req mcp.CallToolRequest
+		req.Params.Arguments = map[string]any{
+			"sql":      "SELECT * FROM users WHERE pin = 'hunter2'",
+			"password": "hunter2",
+		}
+		if _, err := handler(context.Background(), req); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if buf.Len() == 0 || strings.Contains(buf.String(), "hunter2") {
+			t.Fatalf("%s: expected a redacted audit record, got %q", name, buf.String())
+		}
+	}
+}
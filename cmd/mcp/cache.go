@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"td_go_mcp/internal/auth"
+	"td_go_mcp/internal/cache"
+	"td_go_mcp/internal/db"
+	"td_go_mcp/internal/tools"
+)
+
+// results caches the rows of tools with cache_ttl; nil disables caching
+var results *cache.Cache
+
+// newResultCache builds the result cache from the cache config
+func newResultCache() *cache.Cache {
+	cfg := appConfig.Cache
+	return cache.New(cfg.MaxEntries, int64(cfg.MaxSizeMB)<<20)
+}
+
+// cacheKey identifies a tool result by tool, bound parameters (with
+// defaults filled in, so omitting a default hits the same entry),
+// connection and the caller's roles. When queries run under the caller's
+// own database identity the caller is part of the key too, since grants
+// may differ between callers with the same roles.
+func cacheKey(ctx context.Context, toolDef tools.ToolDefinition, params map[string]interface{}) (string, error) {
+	bound := make(map[string]interface{}, len(toolDef.Parameters))
+	for name, param := range toolDef.Parameters {
+		if param.Default != nil {
+			bound[name] = param.Default
+		}
+	}
+	for name, value := range params {
+		bound[name] = value
+	}
+	// encoding/json writes map keys in sorted order
+	encoded, err := json.Marshal(bound)
+	if err != nil {
+		return "", err
+	}
+
+	roles := policy.Roles(auth.PrincipalFromContext(ctx))
+	sort.Strings(roles)
+	parts := []string{toolDef.Name, string(encoded), connectionName(), strings.Join(roles, ",")}
+	if dbConfig != nil && dbConfig.Identity.Mode != "" && dbConfig.Identity.Mode != db.IdentityShared {
+		parts = append(parts, db.UserFromContext(ctx))
+	}
+	return strings.Join(parts, "\x00"), nil
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"td_go_mcp/internal/openapi"
+	tdserver "td_go_mcp/internal/server"
+	"td_go_mcp/internal/tools"
+)
+
+// Subcommands run instead of the server when named by the first argument
+const (
+	cmdOpenAPI  = "openapi"
+	cmdValidate = "validate"
+)
+
+// commandName returns the subcommand named by the first argument, or "" to run the server
+func commandName() string {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case cmdOpenAPI, cmdValidate:
+			return os.Args[1]
+		}
+	}
+	return ""
+}
+
+// runCommand runs a subcommand and returns the process exit code
+func runCommand(name string, args []string) int {
+	switch name {
+	case cmdOpenAPI:
+		return runOpenAPI(args)
+	case cmdValidate:
+		return runValidate(args)
+	}
+	fmt.Fprintf(os.Stderr, "unknown command %q\n", name)
+	return 2
+}
+
+// runOpenAPI writes the OpenAPI spec for the REST API to stdout or a file
+func runOpenAPI(args []string) int {
+	fs := flag.NewFlagSet(cmdOpenAPI, flag.ContinueOnError)
+	serverURL := fs.String("server-url", "/", "URL of the server in the spec's servers section")
+	output := fs.String("o", "", "Write the spec to this file instead of stdout")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Failed to create output file:", err)
+			return 1
+		}
+		defer f.Close()
+		w = f
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(openAPISpec(apiTools(), *serverURL)); err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to write spec:", err)
+		return 1
+	}
+	return 0
+}
+
+// openAPISpec describes defs as served by the REST API
+func openAPISpec(defs []tools.ToolDefinition, serverURL string) map[string]any {
+	return openapi.Generate(defs, openapi.Options{
+		Title:     "td-go-mcp tools",
+		Version:   tdserver.Version,
+		ServerURL: serverURL,
+	})
+}
+
+// openAPIHandler serves the spec at /openapi.json, listing only the tools
+// the caller may run
+func openAPIHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(openAPISpec(authorizedAPITools(r.Context()), "/")); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to encode spec: %v", err), http.StatusInternalServerError)
+	}
+}
+
+// runValidate loads every tool and prompt in a directory, compiling the
+// SQL templates, and reports each problem. It exits non-zero if there are
+// any, so CI can reject bad YAML before it is deployed.
+func runValidate(args []string) int {
+	fs := flag.NewFlagSet(cmdValidate, flag.ContinueOnError)
+	dir := fs.String("dir", "tools", "Directory of tool and prompt YAML files")
+	show := fs.String("show", "", "Print the effective definition of this tool, with its extends chain applied")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	defs, problems, err := tools.LoadTools(*dir)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to read tools:", err)
+		return 1
+	}
+	prompts, promptProblems, err := tools.LoadPrompts(*dir)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to read prompts:", err)
+		return 1
+	}
+	problems = append(problems, promptProblems...)
+	pipelines, pipelineProblems, err := tools.LoadPipelines(*dir)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to read pipelines:", err)
+		return 1
+	}
+	problems = append(problems, pipelineProblems...)
+	known := map[string]bool{}
+	for _, toolDef := range defs {
+		known[toolDef.Name] = true
+	}
+	for _, pipeline := range pipelines {
+		if err := pipelineToolsExist(pipeline, func(name string) bool { return known[name] }); err != nil {
+			problems = append(problems, tools.LoadError{File: pipeline.SourceFile, Error: err.Error()})
+		}
+	}
+
+	// Missing test data only matters when no database is connected, so it is
+	// a warning rather than a problem
+	var warnings []tools.LoadError
+	seen := map[string]string{}
+	for _, toolDef := range defs {
+		if first, ok := seen[toolDef.Name]; ok {
+			problems = append(problems, tools.LoadError{File: toolDef.SourceFile, Error: fmt.Sprintf("tool %s is also defined in %s", toolDef.Name, first)})
+		}
+		seen[toolDef.Name] = toolDef.SourceFile
+		if toolDef.ReturnTestMessage != "" {
+			if _, err := os.Stat(toolDef.ReturnTestMessage); err != nil {
+				warnings = append(warnings, tools.LoadError{File: toolDef.SourceFile, Error: fmt.Sprintf("return_test_message: %v", err)})
+			}
+		}
+	}
+
+	for _, p := range problems {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", p.File, p.Error)
+	}
+	for _, toolDef := range defs {
+		if chain := toolDef.Chain(); len(chain) > 0 {
+			fmt.Printf("%s extends %s\n", toolDef.Name, strings.Join(chain, " -> "))
+		}
+		if toolDef.ForEach != nil {
+			fmt.Printf("%s expands from its for_each query at startup\n", toolDef.Name)
+		}
+	}
+	for _, w := range warnings {
+		fmt.Fprintf(os.Stderr, "%s: warning: %s\n", w.File, w.Error)
+	}
+	if *show != "" {
+		if !showTool(defs, *show) {
+			fmt.Fprintf(os.Stderr, "No valid tool named %s\n", *show)
+			return 1
+		}
+	}
+	fmt.Printf("%d tools, %d pipelines, %d prompts, %d problems\n", len(defs), len(pipelines), len(prompts), len(problems))
+	if len(problems) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// showTool prints a tool's effective definition as YAML
+func showTool(defs []tools.ToolDefinition, name string) bool {
+	for _, toolDef := range defs {
+		if toolDef.Name != name {
+			continue
+		}
+		fmt.Printf("# %s (%s)\n", toolDef.Name, toolDef.SourceFile)
+		enc := yaml.NewEncoder(os.Stdout)
+		enc.SetIndent(2)
+		if err := enc.Encode(toolDef); err != nil {
+			fmt.Fprintln(os.Stderr, "Failed to print tool:", err)
+		}
+		enc.Close()
+		fmt.Println()
+		return true
+	}
+	return false
+}
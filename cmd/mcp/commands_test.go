@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"td_go_mcp/internal/auth"
+	"td_go_mcp/internal/authz"
+	"td_go_mcp/internal/tools"
+)
+
+func TestOpenAPIHandlerListsOnlyAuthorizedTools(t *testing.T) {
+	defer func(defs []tools.ToolDefinition) { loadedTools = defs }(loadedTools)
+	loadedTools = []tools.ToolDefinition{
+		{Name: "list_tables", SQLTemplate: "SELECT 1"},
+		{Name: "salaries", SQLTemplate: "SELECT 2"},
+	}
+	guardTool("list_tables", authz.Rule{})
+	guardTool("salaries", authz.Rule{AllowedRoles: []string{"hr"}})
+	defer func() {
+		toolRules.Lock()
+		delete(toolRules.m, "list_tables")
+		delete(toolRules.m, "salaries")
+		toolRules.Unlock()
+	}()
+
+	paths := func(p *auth.Principal) map[string]any {
+		req := httptest.NewRequest("GET", "/openapi.json", nil)
+		if p != nil {
+			req = req.WithContext(auth.WithPrincipal(req.Context(), p))
+		}
+		rec := httptest.NewRecorder()
+		openAPIHandler(rec, req)
+		var spec struct {
+			Paths map[string]any `json:"paths"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &spec); err != nil {
+			t.Fatalf("expected a JSON spec, got %q", rec.Body.String())
+		}
+		return spec.Paths
+	}
+
+	got := paths(&auth.Principal{Subject: "alice", Roles: []string{"analyst"}})
+	if got["/api/tools/list_tables"] == nil || got["/api/tools/salaries"] != nil {
+		t.Fatalf("expected only list_tables for an unprivileged caller, got %v", got)
+	}
+	if got := paths(nil); got["/api/tools/salaries"] != nil {
+		t.Fatalf("expected salaries hidden from an anonymous caller, got %v", got)
+	}
+	if got := paths(&auth.Principal{Subject: "bob", Roles: []string{"hr"}}); got["/api/tools/salaries"] == nil {
+		t.Fatalf("expected salaries for an hr caller, got %v", got)
+	}
+}
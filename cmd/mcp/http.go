@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os/signal"
+	"path"
+	"syscall"
+	"time"
+
+	"td_go_mcp/internal/config"
+
+	"github.com/mark3labs/mcp-go/server"
+	"golang.org/x/exp/slog"
+)
+
+// serveHTTP serves the MCP server over streamable HTTP at <base>/mcp and
+// over the legacy SSE transport at <base>/sse and <base>/message until
+// SIGINT or SIGTERM, then shuts down gracefully. With withAdmin the admin
+// endpoints and REST tools API are served on the same listener.
+func serveHTTP(mcpServer *server.MCPServer, cfg config.HTTPConfig, withAdmin bool) error {
+	if (cfg.TLSCertFile == "") != (cfg.TLSKeyFile == "") {
+		return fmt.Errorf("both TLS cert and key must be provided")
+	}
+
+	basePath := path.Join("/", cfg.BasePath)
+	httpServer := &http.Server{
+		Addr:              cfg.Listen,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	streamable := server.NewStreamableHTTPServer(mcpServer,
+		server.WithStreamableHTTPServer(httpServer),
+	)
+	sse := server.NewSSEServer(mcpServer,
+		server.WithStaticBasePath(basePath),
+		server.WithHTTPServer(httpServer),
+	)
+
+	mux := http.NewServeMux()
+	mux.Handle(path.Join(basePath, "mcp"), streamable)
+	mux.Handle(sse.CompleteSsePath(), sse)
+	mux.Handle(sse.CompleteMessagePath(), sse)
+	if withAdmin {
+		registerAdminRoutes(mux)
+	}
+	httpServer.Handler = protect(mux)
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	errCh := make(chan error, 1)
+	go func() {
+		slog.Info("Starting MCP server with HTTP transport", "addr", cfg.Listen, "tls", cfg.TLSCertFile != "",
+			"streamable", path.Join(basePath, "mcp"), "sse", sse.CompleteSsePath(), "message", sse.CompleteMessagePath())
+		if cfg.TLSCertFile != "" {
+			errCh <- httpServer.ListenAndServeTLS(cfg.TLSCertFile, cfg.TLSKeyFile)
+			return
+		}
+		errCh <- httpServer.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errCh:
+		if errors.Is(err, http.ErrServerClosed) {
+			return nil
+		}
+		return err
+	case <-ctx.Done():
+	}
+
+	slog.Info("Shutting down HTTP transport", "timeout_seconds", cfg.ShutdownTimeoutSeconds)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.ShutdownTimeoutSeconds)*time.Second)
+	defer cancel()
+	// Closes open SSE streams, then shuts down the shared http.Server
+	if err := sse.Shutdown(shutdownCtx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("shutdown failed: %w", err)
+	}
+	if err := streamable.Shutdown(shutdownCtx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("shutdown failed: %w", err)
+	}
+	return nil
+}
@@ -1,11 +1,15 @@
 package main
 
 import (
+	"fmt"
+	"io"
 	"os"
-	"path/filepath"
-	"time"
 
+	"td_go_mcp/internal/audit"
+	"td_go_mcp/internal/config"
 	"td_go_mcp/internal/db"
+	"td_go_mcp/internal/logging"
+	"td_go_mcp/internal/sqlguard"
 	"td_go_mcp/internal/tools"
 
 	"golang.org/x/exp/slog"
@@ -16,53 +20,139 @@ var (
 	loadedPrompts []tools.PromptDefinition
 	processors    map[string]*tools.SQLProcessor
 	database      *db.DB
+	appConfig     *config.Config
+	auditLog      *audit.Logger
 	logger        *slog.Logger
+	logCloser     io.Closer
+	logBridge     *logging.MCPBridge
+	loadErrors    []tools.LoadError
+	dbConfig      *db.Config
+	dbConnectErr  error
 )
 
-func init() {
-	// Set up logging directory and slog logger
-	logDir := "logging"
-	if err := os.MkdirAll(logDir, 0755); err != nil {
-		slog.Error("Failed to create logging directory", "err", err)
-		os.Exit(1)
-	}
-	logFile := filepath.Join(logDir, time.Now().Format("2006-01-02_15-04-05")+".log")
-	f, err := os.OpenFile(logFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+// setup loads the configuration, opens the debug and audit logs, loads the
+// YAML tools and connects to the database. main calls it first, rather than
+// init, so tests of this package start without those side effects.
+func setup() {
+	appConfig = config.Load()
+
+	// Set up the slog logger from the logging config, falling back to stderr
+	var err error
+	logger, logCloser, err = logging.New(appConfig.Logging)
 	if err != nil {
-		slog.Error("Failed to open log file", "err", err)
-		os.Exit(1)
+		logger = slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{AddSource: true}))
+		logCloser = io.NopCloser(nil)
+		logger.Error("Invalid logging configuration, logging to stderr", "err", err)
 	}
-	logger = slog.New(slog.NewTextHandler(f, &slog.HandlerOptions{AddSource: true}))
+	// Mirror log records to MCP clients that enable logging/setLevel
+	logBridge = logging.NewMCPBridge()
+	logger = slog.New(logBridge.Handler(logger.Handler()))
 	slog.SetDefault(logger)
 
-	loadedTools, err = tools.LoadToolsFromDirectory("tools")
+	// The audit stream is kept separate from the debug log
+	auditLog, err = audit.New(appConfig.Audit)
 	if err != nil {
-		logger.Error("Error loading tools", "err", err)
-		loadedTools = []tools.ToolDefinition{} // Continue with empty tools
+		logger.Error("Failed to open audit log, auditing disabled", "err", err)
+		auditLog = nil
 	}
 
-	processors = make(map[string]*tools.SQLProcessor)
-	for i := range loadedTools {
-		processors[loadedTools[i].Name] = tools.NewSQLProcessor(loadedTools[i])
+	// A bad file skips that tool or prompt only; failures are reported by the admin endpoint
+	var toolErrors, promptErrors []tools.LoadError
+	loadedTools, toolErrors, err = tools.LoadTools("tools")
+	if err != nil {
+		logger.Error("Error loading tools", "err", err)
+		loadedTools = []tools.ToolDefinition{} // Continue with empty tools
 	}
 
 	// Load prompts from YAML files
-	loadedPrompts, err = tools.LoadPromptsFromDirectory("tools")
+	loadedPrompts, promptErrors, err = tools.LoadPrompts("tools")
 	if err != nil {
 		logger.Error("Error loading prompts", "err", err)
 		loadedPrompts = []tools.PromptDefinition{} // Continue with empty prompts
 	}
 
-	logger.Info("Loaded tools and prompts", "tools", len(loadedTools), "prompts", len(loadedPrompts))
+	// Initialize database connection (subcommands only need the tool definitions)
+	dbConfig = db.LoadConfig()
+	if commandName() == "" {
+		database, err = db.Connect(dbConfig)
+		if err != nil {
+			dbConnectErr = err
+			logger.Error("Database connection failed", "err", err)
+			logger.Warn("Continuing without database - SQL preview mode only")
+			database = nil
+		} else {
+			logger.Info("Database connection established successfully")
+		}
+	}
+
+	// Tool families listed by a catalog query expand once the database is up
+	var familyErrors []tools.LoadError
+	loadedTools, familyErrors = expandFamilies(loadedTools)
+	toolErrors = append(toolErrors, familyErrors...)
 
-	// Initialize database connection
-	dbConfig := db.LoadConfig()
-	database, err = db.Connect(dbConfig)
+	// Templates are compiled once here; tools whose template does not
+	// compile are left out and reported with the other load errors
+	processors = make(map[string]*tools.SQLProcessor)
+	compiled := loadedTools[:0]
+	for _, toolDef := range loadedTools {
+		processor, err := tools.NewSQLProcessor(toolDef)
+		if err != nil {
+			toolErrors = append(toolErrors, tools.LoadError{File: toolDef.SourceFile, Error: err.Error()})
+			continue
+		}
+		processors[toolDef.Name] = processor
+		compiled = append(compiled, toolDef)
+	}
+	loadedTools = compiled
+
+	// Pipelines call the tools above, so they load last
+	var pipelineErrors, missingTools []tools.LoadError
+	loadedPipelines, pipelineErrors, err = tools.LoadPipelines("tools")
 	if err != nil {
-		logger.Error("Database connection failed", "err", err)
-		logger.Warn("Continuing without database - SQL preview mode only")
-		database = nil
-	} else {
-		logger.Info("Database connection established successfully")
+		logger.Error("Error loading pipelines", "err", err)
+	}
+	loadedPipelines, missingTools = checkPipelines(loadedPipelines)
+	toolErrors = append(append(toolErrors, pipelineErrors...), missingTools...)
+
+	loadErrors = append(toolErrors, promptErrors...)
+	for _, le := range loadErrors {
+		logger.Error("Skipping invalid YAML file", "file", le.File, "err", le.Error)
+	}
+
+	logger.Info("Loaded tools and prompts", "tools", len(loadedTools), "pipelines", len(loadedPipelines), "prompts", len(loadedPrompts))
+}
+
+// expandFamilies replaces each tool family whose for_each items come from a
+// query with one tool per returned row. Without a database the family is
+// skipped and reported as a load error.
+func expandFamilies(defs []tools.ToolDefinition) ([]tools.ToolDefinition, []tools.LoadError) {
+	var expanded []tools.ToolDefinition
+	var errs []tools.LoadError
+	for _, toolDef := range defs {
+		if toolDef.ForEach == nil {
+			expanded = append(expanded, toolDef)
+			continue
+		}
+		if database == nil {
+			errs = append(errs, tools.LoadError{File: toolDef.SourceFile, Error: "for_each query needs a database connection"})
+			continue
+		}
+		if err := sqlguard.CheckReadOnly(toolDef.ForEach.Query); err != nil {
+			errs = append(errs, tools.LoadError{File: toolDef.SourceFile, Error: fmt.Sprintf("for_each query: %v", err)})
+			continue
+		}
+		rows, err := database.ExecuteQuery(toolDef.ForEach.Query)
+		if err != nil {
+			errs = append(errs, tools.LoadError{File: toolDef.SourceFile, Error: fmt.Sprintf("for_each query: %v", err)})
+			continue
+		}
+		family, err := tools.Expand(toolDef, rows)
+		if err != nil {
+			errs = append(errs, tools.LoadError{File: toolDef.SourceFile, Error: err.Error()})
+			continue
+		}
+		logger.Info("Expanded tool family", "file", toolDef.SourceFile, "tools", len(family))
+		expanded = append(expanded, family...)
 	}
+	return expanded, errs
 }
@@ -1,28 +1,115 @@
-// ...existing code...
 package main
 
 import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
 	"os"
+	"strings"
+	"time"
+
+	"td_go_mcp/internal/audit"
+	"td_go_mcp/internal/auth"
+	tdserver "td_go_mcp/internal/server"
+	"td_go_mcp/internal/tracing"
 
 	"github.com/mark3labs/mcp-go/server"
 	"golang.org/x/exp/slog"
 )
 
-// ...globals and init() moved to init.go...
+// Globals and setup() live in init.go
 
 func main() {
-	// Set up logging to file is handled in init.go
+	// Logging is configured in setup from config.yaml and LOG_* variables
+	setup()
+
+	if name := commandName(); name != "" {
+		code := runCommand(name, os.Args[2:])
+		logCloser.Close()
+		os.Exit(code)
+	}
+
+	transport := flag.String("transport", appConfig.Transport, "MCP transport: stdio or http")
+	flag.StringVar(&appConfig.HTTP.Listen, "listen", appConfig.HTTP.Listen, "HTTP listen address (http transport)")
+	flag.StringVar(&appConfig.HTTP.BasePath, "base-path", appConfig.HTTP.BasePath, "URL path prefix for the MCP endpoints (http transport)")
+	flag.StringVar(&appConfig.HTTP.TLSCertFile, "tls-cert", appConfig.HTTP.TLSCertFile, "TLS certificate file (http transport)")
+	flag.StringVar(&appConfig.HTTP.TLSKeyFile, "tls-key", appConfig.HTTP.TLSKeyFile, "TLS key file (http transport)")
+	flag.StringVar(&appConfig.Admin.Listen, "admin-listen", appConfig.Admin.Listen, "Admin/health endpoint address; empty shares the HTTP listener (http) or disables it (stdio)")
+	flag.Parse()
+
+	var err error
+	authenticator, err = auth.New(appConfig.Auth)
+	if err != nil {
+		slog.Error("Invalid auth configuration", "err", err)
+		fmt.Fprintln(os.Stderr, "Invalid auth configuration:", err)
+		os.Exit(1)
+	}
+	if err := loadPolicy(); err != nil {
+		slog.Error("Invalid authorization configuration", "err", err)
+		fmt.Fprintln(os.Stderr, "Invalid authorization configuration:", err)
+		os.Exit(1)
+	}
+
+	limits, err = newLimits()
+	if err != nil {
+		slog.Error("Invalid rate limit configuration", "err", err)
+		fmt.Fprintln(os.Stderr, "Invalid rate limit configuration:", err)
+		os.Exit(1)
+	}
+	results = newResultCache()
+	pruneCtx, stopPruning := context.WithCancel(context.Background())
+	defer stopPruning()
+	go pruneSessionLimits(pruneCtx)
 
 	defer func() {
 		if database != nil {
 			database.Close()
 		}
+		auditLog.Close()
+		logCloser.Close()
 	}()
 
-	mcpServer := server.NewMCPServer("td-go-mcp", "0.2.0",
+	mode := strings.ToLower(*transport)
+	if mode == "http" && authenticator == nil {
+		slog.Warn("HTTP transport is running without authentication; anyone who can reach it can run tools")
+	}
+
+	// The stdio transport owns stdout, so the stdout span exporter writes to stderr there
+	var spanOut io.Writer = os.Stdout
+	if mode == "stdio" || mode == "" {
+		spanOut = os.Stderr
+	}
+	shutdownTracing, err := tracing.Setup(context.Background(), appConfig.Tracing, spanOut)
+	if err != nil {
+		slog.Error("Tracing disabled", "err", err)
+		shutdownTracing = func(context.Context) error { return nil }
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			slog.Error("Failed to flush traces", "err", err)
+		}
+	}()
+
+	hooks := &server.Hooks{}
+	hooks.AddOnUnregisterSession(func(ctx context.Context, session server.ClientSession) {
+		limits.Forget(session.SessionID())
+	})
+
+	mcpServer := server.NewMCPServer("td-go-mcp", tdserver.Version,
 		server.WithToolCapabilities(true),
 		server.WithPromptCapabilities(true),
+		server.WithLogging(),
+		server.WithHooks(hooks),
+		server.WithToolHandlerMiddleware(tracingMiddleware),
+		server.WithToolHandlerMiddleware(authzMiddleware),
+		server.WithToolHandlerMiddleware(rateLimitMiddleware),
+		server.WithToolFilter(toolFilter),
+		server.WithToolHandlerMiddleware(resultSizeMiddleware),
 	)
+	logBridge.Attach(mcpServer)
 
 	slog.Info("Registering tools and prompts with MCP server", "tools", len(loadedTools), "prompts", len(loadedPrompts))
 
@@ -30,14 +117,40 @@ func main() {
 		addToolToServer(mcpServer, toolDef)
 	}
 
+	for _, pipeline := range loadedPipelines {
+		addPipelineToServer(mcpServer, pipeline)
+	}
+
+	addBuiltinTools(mcpServer)
+
 	for _, promptDef := range loadedPrompts {
 		addPromptToServer(mcpServer, promptDef)
 	}
 
-	slog.Info("Starting MCP server with stdio transport...")
+	// The admin endpoint shares the MCP listener in http mode unless given its own address
+	sharedAdmin := mode == "http" && (appConfig.Admin.Listen == "" || appConfig.Admin.Listen == appConfig.HTTP.Listen)
+	if appConfig.Admin.Listen != "" && !sharedAdmin {
+		adminServer := startAdminServer(appConfig.Admin.Listen)
+		defer adminServer.Close()
+	}
 
-	if err := server.ServeStdio(mcpServer); err != nil {
+	switch mode {
+	case "stdio", "":
+		if strings.EqualFold(appConfig.Audit.Destination, "stdout") {
+			// stdout carries the stdio transport's JSON-RPC messages
+			slog.Warn("Audit destination stdout is reserved for the stdio transport, using stderr")
+			auditLog = audit.NewWriter(os.Stderr)
+		}
+		slog.Info("Starting MCP server with stdio transport...")
+		err = server.ServeStdio(mcpServer, server.WithStdioContextFunc(stdioIdentity))
+	case "http":
+		err = serveHTTP(mcpServer, appConfig.HTTP, sharedAdmin)
+	default:
+		err = fmt.Errorf("unknown transport %q (use stdio or http)", *transport)
+	}
+	if err != nil {
 		slog.Error("Server error", "err", err)
+		fmt.Fprintln(os.Stderr, "Server error:", err)
 		os.Exit(1)
 	}
 }
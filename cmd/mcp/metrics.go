@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+
+	"td_go_mcp/internal/audit"
+	"td_go_mcp/internal/metrics"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// observeToolCall updates the tool call metrics from an audit record
+func observeToolCall(rec audit.Record) {
+	metrics.ToolCalls.WithLabelValues(rec.Tool, rec.Outcome).Inc()
+	if rec.Outcome == audit.OutcomeOK {
+		metrics.RowsReturned.WithLabelValues(rec.Tool).Observe(float64(rec.Rows))
+	}
+}
+
+// resultSizeMiddleware records the size of every tool result sent to clients
+func resultSizeMiddleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		result, err := next(ctx, req)
+		if result != nil {
+			size := 0
+			for _, content := range result.Content {
+				if text, ok := content.(mcp.TextContent); ok {
+					size += len(text.Text)
+				}
+			}
+			metrics.BytesReturned.WithLabelValues(req.Params.Name).Observe(float64(size))
+		}
+		return result, err
+	}
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"td_go_mcp/internal/audit"
+	"td_go_mcp/internal/authz"
+	"td_go_mcp/internal/jsonpath"
+	"td_go_mcp/internal/ratelimit"
+	"td_go_mcp/internal/tools"
+	"td_go_mcp/internal/tracing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/exp/slog"
+)
+
+var loadedPipelines []tools.PipelineDefinition
+
+type pipelineKey struct{}
+
+// pipelineFromContext names the pipeline a tool call runs under, if any
+func pipelineFromContext(ctx context.Context) string {
+	name, _ := ctx.Value(pipelineKey{}).(string)
+	return name
+}
+
+// checkPipelines drops pipelines that call a tool that is not a loaded YAML
+// tool, or whose name is already taken by one
+func checkPipelines(pipelines []tools.PipelineDefinition) ([]tools.PipelineDefinition, []tools.LoadError) {
+	var valid []tools.PipelineDefinition
+	var errs []tools.LoadError
+	for _, pipeline := range pipelines {
+		err := pipelineToolsExist(pipeline, func(name string) bool { return processors[name] != nil })
+		if err != nil {
+			errs = append(errs, tools.LoadError{File: pipeline.SourceFile, Error: err.Error()})
+			continue
+		}
+		valid = append(valid, pipeline)
+	}
+	return valid, errs
+}
+
+// pipelineToolsExist checks the pipeline's name is free and its steps call known tools
+func pipelineToolsExist(pipeline tools.PipelineDefinition, known func(string) bool) error {
+	if known(pipeline.Name) {
+		return fmt.Errorf("pipeline %s has the same name as a tool", pipeline.Name)
+	}
+	for _, step := range pipeline.Steps {
+		if !known(step.Tool) {
+			return fmt.Errorf("step %s calls unknown tool %s", step.Name, step.Tool)
+		}
+	}
+	return nil
+}
+
+func addPipelineToServer(mcpServer *server.MCPServer, pipeline tools.PipelineDefinition) {
+	toolDef := pipeline.Tool()
+	mcpServer.AddTool(convertToolDefinition(toolDef), createPipelineHandler(pipeline))
+	guardTool(toolDef.Name, authz.Rule{AllowedRoles: toolDef.AllowedRoles, AllowedUsers: toolDef.AllowedUsers})
+	if err := limits.SetTool(toolDef.Name, toolDef.Limit()); err != nil {
+		slog.Error("Invalid tool rate limit", "tool", toolDef.Name, "err", err)
+	}
+	registered.addTool(toolDef.Name, toolDef.SourceFile)
+	slog.Info("Registered pipeline", "tool", toolDef.Name, "steps", len(pipeline.Steps))
+}
+
+func createPipelineHandler(pipeline tools.PipelineDefinition) func(context.Context, mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		slog.InfoContext(ctx, "Handling pipeline call", "tool", pipeline.Name)
+		output, err := runPipeline(ctx, pipeline, req.GetArguments())
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		text, err := json.Marshal(output)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("failed to marshal results: %v", err)), nil
+		}
+		return mcp.NewToolResultText(string(text)), nil
+	}
+}
+
+// runPipeline runs each step in order and returns the pipeline's output.
+// Steps are audited as their own tool calls, tagged with the pipeline, and
+// the pipeline call itself is audited when it finishes.
+func runPipeline(ctx context.Context, pipeline tools.PipelineDefinition, args map[string]interface{}) (output any, err error) {
+	start := time.Now()
+	toolDef := pipeline.Tool()
+	outcome := audit.OutcomeOK
+	defer func() {
+		rec := audit.Record{
+			Tool:       pipeline.Name,
+			Arguments:  audit.RedactArguments(args, toolDef.SecretParameters()),
+			Connection: connectionName(),
+			DurationMS: time.Since(start).Milliseconds(),
+			Outcome:    outcome,
+		}
+		if err != nil {
+			rec.Error = err.Error()
+		}
+		recordAudit(ctx, rec)
+	}()
+
+	if preview, _ := args["__preview"].(bool); preview {
+		outcome = audit.OutcomeRejected
+		return nil, fmt.Errorf("pipelines cannot be previewed; preview their step tools instead")
+	}
+	params := make(map[string]interface{}, len(toolDef.Parameters))
+	for name, param := range toolDef.Parameters {
+		if value, ok := args[name]; ok {
+			params[name] = value
+		} else if param.Default != nil {
+			params[name] = param.Default
+		}
+	}
+	for _, name := range toolDef.Required {
+		if _, ok := params[name]; !ok {
+			outcome = audit.OutcomeRejected
+			return nil, fmt.Errorf("parameter validation failed: missing required parameter: %s", name)
+		}
+	}
+
+	doc, err := toJSONValue(map[string]any{"params": params})
+	if err != nil {
+		outcome = audit.OutcomeError
+		return nil, err
+	}
+	data := doc.(map[string]any)
+	steps := map[string]any{}
+	data["steps"] = steps
+
+	stepCtx := context.WithValue(ctx, pipelineKey{}, pipeline.Name)
+	for _, step := range pipeline.Steps {
+		result, err := runPipelineStep(stepCtx, step, data)
+		if err != nil {
+			var te *toolError
+			if errors.As(err, &te) {
+				outcome = te.Outcome
+			} else {
+				outcome = audit.OutcomeError
+			}
+			return nil, fmt.Errorf("step %s: %w", step.Name, err)
+		}
+		steps[step.Name] = result
+	}
+
+	if pipeline.Output == nil {
+		return steps, nil
+	}
+	output, err = jsonpath.Resolve(pipeline.Output, data)
+	if err != nil {
+		outcome = audit.OutcomeError
+		return nil, fmt.Errorf("output: %w", err)
+	}
+	return output, nil
+}
+
+// runPipelineStep calls the step's tool, once per item when it fans out,
+// in a span of its own
+func runPipelineStep(ctx context.Context, step tools.PipelineStep, data map[string]any) (result any, err error) {
+	ctx, span := tracing.Tracer().Start(ctx, "pipeline.step",
+		trace.WithAttributes(tracing.AttrPipelineStep.String(step.Name), tracing.AttrTool.String(step.Tool)))
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
+
+	if step.ForEach == "" {
+		return callStepTool(ctx, step, data)
+	}
+	items, err := jsonpath.Resolve(step.ForEach, data)
+	if err != nil {
+		return nil, err
+	}
+	list, ok := items.([]any)
+	if !ok {
+		return nil, fmt.Errorf("for_each %s is not an array", step.ForEach)
+	}
+	if len(list) > step.Limit() {
+		return nil, fmt.Errorf("for_each %s has %d items, more than max_items %d", step.ForEach, len(list), step.Limit())
+	}
+	results := make([]any, 0, len(list))
+	for _, item := range list {
+		itemData := make(map[string]any, len(data)+1)
+		for k, v := range data {
+			itemData[k] = v
+		}
+		itemData["item"] = item
+		result, err := callStepTool(ctx, step, itemData)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// callStepTool resolves the step's arguments and runs its tool through the
+// same checks, limits and audit path as a direct call. The result is the
+// tool's rows and count, plus results for a multi-statement tool and result
+// for one with a transform.
+func callStepTool(ctx context.Context, step tools.PipelineStep, data map[string]any) (any, error) {
+	var toolDef tools.ToolDefinition
+	for _, def := range loadedTools {
+		if def.Name == step.Tool {
+			toolDef = def
+			break
+		}
+	}
+	if err := authorizeAndAudit(ctx, step.Tool); err != nil {
+		return nil, &toolError{Outcome: audit.OutcomeRejected, Err: err}
+	}
+	resolved, err := jsonpath.Resolve(map[string]any(step.Args), data)
+	if err != nil {
+		return nil, err
+	}
+	args, _ := resolved.(map[string]any)
+	release, err := admit(ctx, step.Tool)
+	if err != nil {
+		outcome := audit.OutcomeError
+		if errors.Is(err, ratelimit.ErrLimited) {
+			outcome = audit.OutcomeThrottled
+		}
+		return nil, &toolError{Outcome: outcome, Err: err}
+	}
+	defer release()
+
+	run, err := runYAMLTool(ctx, toolDef, args, nil)
+	if err != nil {
+		return nil, err
+	}
+	rows := run.Rows
+	if run.NoDatabase {
+		var ok bool
+		if rows, ok = testRows(run.TestData); !ok {
+			return nil, &toolError{Outcome: audit.OutcomeTestData, Err: fmt.Errorf("database connection not available and %s has no test rows", step.Tool)}
+		}
+	}
+	output := map[string]any{"rows": nonNil(rows), "count": len(rows)}
+	if run.Results != nil {
+		output["results"] = run.Results
+	}
+	if run.Transformed {
+		output["result"] = run.Output
+	}
+	return toJSONValue(output)
+}
+
+// toJSONValue converts v to the plain maps, slices and scalars JSON decodes
+// to, so paths see rows the way clients do
+func toJSONValue(v any) (any, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var out any
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
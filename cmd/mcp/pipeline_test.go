@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"td_go_mcp/internal/audit"
+	"td_go_mcp/internal/db"
+	"td_go_mcp/internal/ratelimit"
+	"td_go_mcp/internal/tools"
+)
+
+func TestPipelineStepsAreRateLimited(t *testing.T) {
+	rowsFile := filepath.Join(t.TempDir(), "user.json")
+	if err := os.WriteFile(rowsFile, []byte(`[{"user_id": "1"}]`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	toolDef := tools.ToolDefinition{
+		Name:              "lookup_user",
+		SQLTemplate:       "SELECT * FROM users WHERE user_id = '{{.user_id}}'",
+		Parameters:        map[string]tools.Parameter{"user_id": {Type: "string"}},
+		RateLimit:         "2/m",
+		ReturnTestMessage: rowsFile,
+	}
+	processor, err := tools.NewSQLProcessor(toolDef)
+	if err != nil {
+		t.Fatalf("NewSQLProcessor: %v", err)
+	}
+	defer func(tools []tools.ToolDefinition, procs map[string]*tools.SQLProcessor, lim *ratelimit.Manager, conn *db.DB) {
+		loadedTools, processors, limits, database = tools, procs, lim, conn
+	}(loadedTools, processors, limits, database)
+	loadedTools = []tools.ToolDefinition{toolDef}
+	processors = map[string]*tools.SQLProcessor{toolDef.Name: processor}
+	database = nil
+
+	pipeline := tools.PipelineDefinition{
+		Name:       "lookup_users",
+		Parameters: map[string]tools.Parameter{"ids": {Type: "array"}},
+		Steps: []tools.PipelineStep{
+			{Name: "users", Tool: "lookup_user", ForEach: "$.params.ids", Args: map[string]any{"user_id": "$.item"}},
+		},
+	}
+	newLimits := func() {
+		limits, err = ratelimit.NewManager(ratelimit.Limit{}, ratelimit.Limit{}, ratelimit.Queue{})
+		if err != nil {
+			t.Fatalf("NewManager: %v", err)
+		}
+		if err := limits.SetTool(toolDef.Name, toolDef.Limit()); err != nil {
+			t.Fatalf("SetTool: %v", err)
+		}
+	}
+
+	newLimits()
+	if _, err := runPipeline(context.Background(), pipeline, map[string]any{"ids": []any{"1", "2"}}); err != nil {
+		t.Fatalf("expected two calls within lookup_user's 2/m, got %v", err)
+	}
+
+	// One pipeline call must not fan out past the step tool's own limit
+	newLimits()
+	_, err = runPipeline(context.Background(), pipeline, map[string]any{"ids": []any{"1", "2", "3"}})
+	var te *toolError
+	if !errors.Is(err, ratelimit.ErrLimited) || !strings.Contains(err.Error(), "step users") {
+		t.Fatalf("expected the third step call to be rate limited, got %v", err)
+	}
+	if !errors.As(err, &te) || te.Outcome != audit.OutcomeThrottled {
+		t.Fatalf("expected a throttled outcome, got %+v", te)
+	}
+
+	// A call cancelled while it queues for the step tool is an error, not throttling
+	limits, err = ratelimit.NewManager(ratelimit.Limit{}, ratelimit.Limit{}, ratelimit.Queue{Timeout: time.Second})
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	if err := limits.SetTool(toolDef.Name, ratelimit.Limit{MaxConcurrency: 1}); err != nil {
+		t.Fatalf("SetTool: %v", err)
+	}
+	hold, err := limits.Acquire(context.Background(), toolDef.Name, "")
+	if err != nil {
+		t.Fatalf("Acquire: %v", err)
+	}
+	defer hold()
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = runPipeline(ctx, pipeline, map[string]any{"ids": []any{"1"}})
+	if !errors.As(err, &te) || te.Outcome != audit.OutcomeError || errors.Is(err, ratelimit.ErrLimited) {
+		t.Fatalf("expected an error outcome for a cancelled wait, got %v (%+v)", err, te)
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"td_go_mcp/internal/db"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"golang.org/x/exp/slog"
+)
+
+// progressReporter sends notifications/progress for a call whose request
+// carried a progress token. A nil reporter does nothing.
+type progressReporter struct {
+	ctx      context.Context
+	srv      *server.MCPServer
+	token    mcp.ProgressToken
+	progress float64
+}
+
+// newProgressReporter returns nil when the client did not ask for progress
+func newProgressReporter(ctx context.Context, req mcp.CallToolRequest) *progressReporter {
+	if req.Params.Meta == nil || req.Params.Meta.ProgressToken == nil {
+		return nil
+	}
+	srv := server.ServerFromContext(ctx)
+	if srv == nil {
+		return nil
+	}
+	return &progressReporter{ctx: ctx, srv: srv, token: req.Params.Meta.ProgressToken}
+}
+
+// Phase reports that the call moved to a new phase, e.g. "rendering"
+func (p *progressReporter) Phase(message string) {
+	if p == nil {
+		return
+	}
+	// progress must increase with every notification
+	p.progress++
+	err := p.srv.SendNotificationToClient(p.ctx, "notifications/progress", map[string]any{
+		"progressToken": p.token,
+		"progress":      p.progress,
+		"message":       message,
+	})
+	if err != nil {
+		slog.DebugContext(p.ctx, "Failed to send progress notification", "err", err)
+	}
+}
+
+// Query adapts the reporter to db.QueryOptions.Progress
+func (p *progressReporter) Query() func(phase string, rows int) {
+	if p == nil {
+		return nil
+	}
+	return func(phase string, rows int) {
+		if phase == db.PhaseFetching && rows > 0 {
+			p.Phase(fmt.Sprintf("%s: %d rows so far", phase, rows))
+			return
+		}
+		p.Phase(phase)
+	}
+}
+
+// Heartbeat adapts the reporter to db.QueryOptions.Heartbeat, so a long
+// statement keeps reporting before its first row arrives
+func (p *progressReporter) Heartbeat() func(elapsed time.Duration) {
+	if p == nil {
+		return nil
+	}
+	return func(elapsed time.Duration) {
+		p.Phase(fmt.Sprintf("%s: %s elapsed", db.PhaseExecuting, elapsed.Round(time.Second)))
+	}
+}
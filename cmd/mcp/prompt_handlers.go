@@ -7,6 +7,7 @@ import (
 
 	"golang.org/x/exp/slog"
 
+	"td_go_mcp/internal/metrics"
 	"td_go_mcp/internal/tools"
 
 	"github.com/mark3labs/mcp-go/mcp"
@@ -19,6 +20,7 @@ func addPromptToServer(mcpServer *server.MCPServer, promptDef tools.PromptDefini
 	mcpPrompt := convertPromptDefinition(promptDef)
 	handler := createPromptHandler(promptDef)
 	mcpServer.AddPrompt(mcpPrompt, handler)
+	registered.addPrompt(promptDef.Name, promptDef.SourceFile)
 	slog.Info("Registered prompt", "prompt", promptDef.Name)
 }
 
@@ -42,7 +44,8 @@ func convertPromptDefinition(promptDef tools.PromptDefinition) mcp.Prompt {
 
 func createPromptHandler(promptDef tools.PromptDefinition) func(context.Context, mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
 	return func(ctx context.Context, req mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
-		slog.Info("Handling prompt request", "prompt", promptDef.Name)
+		slog.InfoContext(ctx, "Handling prompt request", "prompt", promptDef.Name)
+		metrics.PromptGets.WithLabelValues(promptDef.Name).Inc()
 		args := req.Params.Arguments
 		if args == nil {
 			args = make(map[string]string)
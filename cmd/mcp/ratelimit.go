@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"td_go_mcp/internal/audit"
+	"td_go_mcp/internal/auth"
+	"td_go_mcp/internal/ratelimit"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"golang.org/x/exp/slog"
+)
+
+// limits applies the rate and concurrency limits; nil until main creates it
+var limits *ratelimit.Manager
+
+// newLimits builds the limit manager from the rate_limit config
+func newLimits() (*ratelimit.Manager, error) {
+	cfg := appConfig.RateLimit
+	return ratelimit.NewManager(cfg.Global, cfg.PerSession, ratelimit.Queue{
+		Timeout:    time.Duration(cfg.QueueTimeoutSeconds * float64(time.Second)),
+		MaxWaiting: cfg.MaxQueue,
+	})
+}
+
+// clientKey identifies the caller for per-session limits: the MCP session,
+// or the authenticated principal for REST calls
+func clientKey(ctx context.Context) string {
+	if sessionID, _ := sessionInfo(ctx); sessionID != "" {
+		return sessionID
+	}
+	if p := auth.PrincipalFromContext(ctx); p != nil {
+		return "principal:" + p.Subject
+	}
+	return ""
+}
+
+// admit waits for the limits on tool and returns the function to call when
+// the call finishes. Calls turned away are audited as throttled. A pipeline
+// step already runs within its pipeline's session and global slots, so it
+// only takes their tokens.
+func admit(ctx context.Context, tool string) (func(), error) {
+	acquire := limits.Acquire
+	if pipelineFromContext(ctx) != "" {
+		acquire = limits.AcquireStep
+	}
+	release, err := acquire(ctx, tool, clientKey(ctx))
+	if err != nil {
+		outcome := audit.OutcomeError
+		if errors.Is(err, ratelimit.ErrLimited) {
+			outcome = audit.OutcomeThrottled
+			slog.WarnContext(ctx, "Tool call throttled", "tool", tool, "err", err)
+		}
+		recordAudit(ctx, audit.Record{
+			Tool:       tool,
+			Connection: connectionName(),
+			Outcome:    outcome,
+			Error:      err.Error(),
+		})
+		return nil, err
+	}
+	return release, nil
+}
+
+// rateLimitMiddleware holds each tool call to the global, tool and session limits
+func rateLimitMiddleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		release, err := admit(ctx, req.Params.Name)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		defer release()
+		return next(ctx, req)
+	}
+}
+
+// pruneSessionLimits drops the limiters of idle REST callers until ctx is done
+func pruneSessionLimits(ctx context.Context) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			limits.Prune(10 * time.Minute)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -3,14 +3,23 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"strings"
+	"time"
 
+	"td_go_mcp/internal/audit"
+	"td_go_mcp/internal/authz"
+	"td_go_mcp/internal/db"
+	"td_go_mcp/internal/metrics"
+	"td_go_mcp/internal/sqlguard"
 	"td_go_mcp/internal/tools"
+	"td_go_mcp/internal/tracing"
 
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
+	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/exp/slog"
 )
 
@@ -24,6 +33,11 @@ func addToolToServer(mcpServer *server.MCPServer, toolDef tools.ToolDefinition)
 		handler = createToolHandler(toolDef)
 	}
 	mcpServer.AddTool(convertToolDefinition(toolDef), handler)
+	guardTool(toolDef.Name, authz.Rule{AllowedRoles: toolDef.AllowedRoles, AllowedUsers: toolDef.AllowedUsers})
+	if err := limits.SetTool(toolDef.Name, toolDef.Limit()); err != nil {
+		slog.Error("Invalid tool rate limit", "tool", toolDef.Name, "err", err)
+	}
+	registered.addTool(toolDef.Name, toolDef.SourceFile)
 	slog.Info("Registered tool", "tool", toolDef.Name)
 }
 
@@ -82,73 +96,315 @@ func convertToolDefinition(toolDef tools.ToolDefinition) mcp.Tool {
 
 func createToolHandler(toolDef tools.ToolDefinition) func(context.Context, mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-		slog.Info("Handling tool call", "tool", toolDef.Name)
-		processor, exists := processors[toolDef.Name]
-		if !exists {
-			return mcp.NewToolResultError(fmt.Sprintf("tool processor not found: %s", toolDef.Name)), nil
-		}
-		args := req.GetArguments()
-		params := make(map[string]interface{})
-		for paramName := range toolDef.Parameters {
-			if value, exists := args[paramName]; exists {
-				params[paramName] = value
-			}
+		slog.InfoContext(ctx, "Handling tool call", "tool", toolDef.Name)
+		run, err := runYAMLTool(ctx, toolDef, req.GetArguments(), newProgressReporter(ctx, req))
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
 		}
-		preview := false
-		if args["__preview"] != nil {
-			if b, ok := args["__preview"].(bool); ok {
-				preview = b
-			}
-			delete(params, "__preview")
+		text, err := run.mcpText()
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
 		}
-		if err := processor.ValidateParameters(params); err != nil {
-			return mcp.NewToolResultError(fmt.Sprintf("parameter validation failed: %v", err)), nil
+		result := mcp.NewToolResultText(text)
+		if run.Cached {
+			result.Meta = mcp.NewMetaFromMap(map[string]any{"cache": map[string]any{
+				"hit":       true,
+				"stored_at": run.CachedAt.UTC().Format(time.RFC3339),
+			}})
 		}
-		sql, err := processor.ProcessTemplate(params)
+		return result, nil
+	}
+}
+
+// toolRun is the result of a YAML tool call, shared by the MCP handler and
+// the REST API
+type toolRun struct {
+	tool    tools.ToolDefinition
+	SQL     string
+	Preview bool
+	// Partials are the partials the tool's template calls, shown in previews
+	Partials []string
+	// Rows is set when the query ran against the database, or came from the
+	// result cache (Cached, stored at CachedAt)
+	Rows     []map[string]interface{}
+	Cached   bool
+	CachedAt time.Time
+	// Results holds each statement's rows for a multi-statement tool, whose
+	// Rows are those of the last statement
+	Results map[string][]map[string]interface{}
+	// Output is the result of the tool's transform: the rows (or test rows)
+	// reshaped, or a single value. Transformed is set when there is one.
+	Output      any
+	Transformed bool
+	// NoDatabase means the SQL was not run; TestData holds the tool's
+	// return_test_message, if any, or TestDataErr why it could not be read
+	NoDatabase  bool
+	TestData    interface{}
+	TestDataErr error
+}
+
+// toolError classifies a failed tool call by its audit outcome. Database
+// is set when the statement reached the database and failed there.
+type toolError struct {
+	Outcome  string
+	Database bool
+	Err      error
+}
+
+func (e *toolError) Error() string { return e.Err.Error() }
+func (e *toolError) Unwrap() error { return e.Err }
+
+// runYAMLTool validates args, renders the tool's SQL and runs it (or
+// previews it when args has "__preview": true). Every call is audited.
+func runYAMLTool(ctx context.Context, toolDef tools.ToolDefinition, args map[string]interface{}, progress *progressReporter) (*toolRun, error) {
+	secrets := toolDef.SecretParameters()
+	start := time.Now()
+	run := &toolRun{tool: toolDef}
+	outcome := audit.OutcomeOK
+	var callErr error
+
+	// Every call is recorded in the audit stream, whatever the outcome
+	defer func() {
+		rec := audit.Record{
+			Tool:       toolDef.Name,
+			Arguments:  audit.RedactArguments(args, secrets),
+			SQL:        audit.RedactSQL(run.SQL, args, secrets),
+			Connection: connectionName(),
+			Rows:       len(run.Rows),
+			Cached:     run.Cached,
+			DurationMS: time.Since(start).Milliseconds(),
+			Outcome:    outcome,
+		}
+		if callErr != nil {
+			rec.Error = callErr.Error()
+		}
+		recordAudit(ctx, rec)
+	}()
+	fail := func(kind string, err error) (*toolRun, error) {
+		outcome = kind
+		callErr = err
+		return nil, &toolError{Outcome: kind, Err: err}
+	}
+
+	processor, exists := processors[toolDef.Name]
+	if !exists {
+		return fail(audit.OutcomeError, fmt.Errorf("tool processor not found: %s", toolDef.Name))
+	}
+	params := make(map[string]interface{})
+	for paramName := range toolDef.Parameters {
+		if value, exists := args[paramName]; exists {
+			params[paramName] = value
+		}
+	}
+	if args["__preview"] != nil {
+		if b, ok := args["__preview"].(bool); ok {
+			run.Preview = b
+		}
+		delete(params, "__preview")
+	}
+	delete(params, "__no_cache")
+	_, validateSpan := tracing.Tracer().Start(ctx, "validate")
+	err := processor.ValidateParameters(params)
+	validateSpan.End()
+	if err != nil {
+		return fail(audit.OutcomeRejected, fmt.Errorf("parameter validation failed: %v", err))
+	}
+	run.Partials = processor.Partials()
+	// Multi-statement tools that will run are rendered statement by statement
+	// in runStatements, as later statements may read fields of earlier
+	// results that ProcessTemplate only has placeholders for
+	statements := len(processor.Statements()) > 0 && !run.Preview && database != nil
+	if !statements {
+		progress.Phase("rendering")
+		_, renderSpan := tracing.Tracer().Start(ctx, "render")
+		run.SQL, err = processor.ProcessTemplate(params)
+		renderSpan.End()
 		if err != nil {
-			return mcp.NewToolResultError(fmt.Sprintf("SQL template processing failed: %v", err)), nil
+			return fail(audit.OutcomeError, fmt.Errorf("SQL template processing failed: %v", err))
 		}
-		if strings.TrimSpace(sql) == "" {
-			return mcp.NewToolResultError("generated SQL is empty"), nil
+		if strings.TrimSpace(run.SQL) == "" {
+			return fail(audit.OutcomeError, fmt.Errorf("generated SQL is empty"))
 		}
-		if preview {
-			return mcp.NewToolResultText("Generated SQL:\n" + sql), nil
-		} else {
-			if database == nil {
-				if toolDef.ReturnTestMessage != "" {
-					testData, err := loadTestMessage(toolDef.ReturnTestMessage)
-					if err != nil {
-						return mcp.NewToolResultText(fmt.Sprintf("Database connection not available and failed to load test data: %v\n\nGenerated SQL:\n%s", err, sql)), nil
-					}
-					result := map[string]interface{}{
-						"data":   testData,
-						"source": "test_message",
-						"file":   toolDef.ReturnTestMessage,
-						"sql":    sql,
-					}
-					resultJSON, err := json.Marshal(result)
-					if err != nil {
-						return mcp.NewToolResultError(fmt.Sprintf("failed to marshal test results: %v", err)), nil
-					}
-					return mcp.NewToolResultText(string(resultJSON)), nil
-				}
-				return mcp.NewToolResultText("Database connection not available. Use '__preview': true to see generated SQL.\n\nGenerated SQL:\n" + sql), nil
+		trace.SpanFromContext(ctx).SetAttributes(tracing.AttrStatementHash.String(tracing.StatementHash(run.SQL)))
+		slog.DebugContext(ctx, "Rendered SQL", "tool", toolDef.Name, "sql", audit.RedactSQL(run.SQL, args, secrets))
+	}
+	if run.Preview {
+		outcome = audit.OutcomePreview
+		return run, nil
+	}
+
+	if database == nil {
+		slog.WarnContext(ctx, "Database connection not available, returning test data", "tool", toolDef.Name)
+		outcome = audit.OutcomeTestData
+		metrics.TestDataFallbacks.WithLabelValues(toolDef.Name).Inc()
+		run.NoDatabase = true
+		if toolDef.ReturnTestMessage != "" {
+			run.TestData, run.TestDataErr = loadTestMessage(toolDef.ReturnTestMessage)
+			callErr = run.TestDataErr
+		}
+		if rows, ok := testRows(run.TestData); ok {
+			if err := run.applyTransform(rows); err != nil {
+				return fail(audit.OutcomeError, err)
 			}
-			rows, err := database.ExecuteQuery(sql)
+		}
+		return run, nil
+	}
+
+	// Read-only tools with cache_ttl are answered from the result cache
+	// unless the caller passes "__no_cache": true
+	ttl := toolDef.CacheDuration()
+	var key string
+	if ttl > 0 && results != nil {
+		if err := sqlguard.CheckReadOnly(run.SQL); err != nil {
+			slog.WarnContext(ctx, "Not caching result of a tool that is not read-only", "tool", toolDef.Name, "err", err)
+		} else if key, err = cacheKey(ctx, toolDef, params); err != nil {
+			slog.WarnContext(ctx, "Failed to build cache key", "tool", toolDef.Name, "err", err)
+			key = ""
+		}
+	}
+	if key != "" {
+		if noCache, _ := args["__no_cache"].(bool); noCache {
+			metrics.CacheLookups.WithLabelValues(toolDef.Name, "bypass").Inc()
+		} else if entry, ok := results.Get(key); ok {
+			metrics.CacheLookups.WithLabelValues(toolDef.Name, "hit").Inc()
+			run.Rows, run.Cached, run.CachedAt = entry.Value.([]map[string]interface{}), true, entry.Stored
+			progress.Phase(fmt.Sprintf("done: %d cached rows", len(run.Rows)))
+			slog.InfoContext(ctx, "Served from result cache", "tool", toolDef.Name, "rows", len(run.Rows), "age_ms", time.Since(entry.Stored).Milliseconds())
+			if err := run.applyTransform(run.Rows); err != nil {
+				return fail(audit.OutcomeError, err)
+			}
+			return run, nil
+		} else {
+			metrics.CacheLookups.WithLabelValues(toolDef.Name, "miss").Inc()
+		}
+	}
+
+	queryStart := time.Now()
+	opts := db.QueryOptions{Progress: progress.Query(), Heartbeat: progress.Heartbeat(), QueryBand: queryBand(ctx, toolDef.Name)}
+	var rows []map[string]interface{}
+	if statements {
+		err = runStatements(ctx, processor, params, opts, run)
+		rows = run.Rows
+		trace.SpanFromContext(ctx).SetAttributes(tracing.AttrStatementHash.String(tracing.StatementHash(run.SQL)))
+	} else {
+		rows, _, err = database.ExecuteQueryContext(ctx, run.SQL, opts)
+	}
+	if errors.Is(err, db.ErrNoIdentity) {
+		return fail(audit.OutcomeRejected, err)
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "SQL execution failed", "tool", toolDef.Name, "duration_ms", time.Since(queryStart).Milliseconds(), "err", err)
+		outcome, callErr = audit.OutcomeError, fmt.Errorf("SQL execution failed: %v", err)
+		return nil, &toolError{Outcome: outcome, Database: true, Err: callErr}
+	}
+	run.Rows = rows
+	if key != "" {
+		if encoded, err := json.Marshal(rows); err == nil {
+			results.Set(key, rows, int64(len(encoded)), ttl)
+		}
+	}
+	trace.SpanFromContext(ctx).SetAttributes(tracing.AttrRows.Int(len(rows)))
+	progress.Phase(fmt.Sprintf("done: %d rows", len(rows)))
+	slog.InfoContext(ctx, "SQL executed", "tool", toolDef.Name, "rows", len(rows), "duration_ms", time.Since(queryStart).Milliseconds())
+	if err := run.applyTransform(rows); err != nil {
+		return fail(audit.OutcomeError, err)
+	}
+	return run, nil
+}
+
+// applyTransform sets Output from rows when the tool declares a transform.
+// Cached rows are stored untransformed, so they are reshaped on every hit.
+func (r *toolRun) applyTransform(rows []map[string]interface{}) error {
+	if !r.tool.HasTransform() {
+		return nil
+	}
+	output, err := r.tool.ApplyTransform(nonNil(rows))
+	if err != nil {
+		return err
+	}
+	r.Output, r.Transformed = output, true
+	return nil
+}
+
+// runStatements runs a multi-statement tool's statements in order on one
+// connection, rendering each with the results of the ones before it
+func runStatements(ctx context.Context, processor *tools.SQLProcessor, params map[string]interface{}, opts db.QueryOptions, run *toolRun) error {
+	stmts := processor.Statements()
+	run.Results = make(map[string][]map[string]interface{}, len(stmts))
+	values := make(map[string]any, len(stmts))
+	rendered := make([]string, 0, len(stmts))
+	defer func() { run.SQL = strings.Join(rendered, ";\n\n") }()
+	return database.WithSession(ctx, opts, run.tool.Transaction, func(session *db.Session) error {
+		for i, stmt := range stmts {
+			sql, err := processor.ProcessStatement(i, params, values)
 			if err != nil {
-				return mcp.NewToolResultError(fmt.Sprintf("SQL execution failed: %v", err)), nil
+				return fmt.Errorf("statement %s: %w", stmt.Name, err)
 			}
-			resultJSON, err := json.Marshal(map[string]interface{}{
-				"rows":  rows,
-				"count": len(rows),
-				"sql":   sql,
-			})
+			rendered = append(rendered, sql)
+			rows, _, err := session.Query(ctx, sql)
 			if err != nil {
-				return mcp.NewToolResultError(fmt.Sprintf("failed to marshal results: %v", err)), nil
+				return fmt.Errorf("statement %s: %w", stmt.Name, err)
 			}
-			return mcp.NewToolResultText(string(resultJSON)), nil
+			run.Results[stmt.Name] = nonNil(rows)
+			run.Rows = rows
+			values[stmt.Name] = tools.StatementValue(rows)
+		}
+		return nil
+	})
+}
+
+// mcpText formats a tool run as the text content of an MCP tool result
+func (r *toolRun) mcpText() (string, error) {
+	switch {
+	case r.Preview && len(r.Partials) > 0:
+		return "Generated SQL:\n" + r.SQL + "\n\nPartials: " + strings.Join(r.Partials, ", "), nil
+	case r.Preview:
+		return "Generated SQL:\n" + r.SQL, nil
+	case r.NoDatabase && r.TestDataErr != nil:
+		return fmt.Sprintf("Database connection not available and failed to load test data: %v\n\nGenerated SQL:\n%s", r.TestDataErr, r.SQL), nil
+	case r.NoDatabase && r.TestData != nil:
+		data := r.TestData
+		if r.Transformed {
+			data = r.Output
+		}
+		resultJSON, err := json.Marshal(map[string]interface{}{
+			"data":   data,
+			"source": "test_message",
+			"file":   r.tool.ReturnTestMessage,
+			"sql":    r.SQL,
+		})
+		if err != nil {
+			return "", fmt.Errorf("failed to marshal test results: %v", err)
 		}
+		return string(resultJSON), nil
+	case r.NoDatabase:
+		return "Database connection not available. Use '__preview': true to see generated SQL.\n\nGenerated SQL:\n" + r.SQL, nil
+	}
+	result := map[string]interface{}{
+		"rows":  r.Rows,
+		"count": len(r.Rows),
+		"sql":   r.SQL,
+	}
+	if r.Transformed || r.Results != nil {
+		result = map[string]interface{}{"sql": r.SQL}
+	}
+	if r.Transformed {
+		result["result"] = r.Output
+	}
+	if r.Results != nil {
+		// A transform reshapes the last statement's rows; every statement's
+		// rows are still returned
+		result["results"] = r.Results
+	}
+	if r.Cached {
+		result["cached"] = true
+		result["cached_at"] = r.CachedAt.UTC().Format(time.RFC3339)
+	}
+	resultJSON, err := json.Marshal(result)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal results: %v", err)
 	}
+	return string(resultJSON), nil
 }
 
 func loadTestMessage(filepath string) (interface{}, error) {
@@ -187,6 +443,7 @@ func connectionStatusHandler(ctx context.Context, req mcp.CallToolRequest) (*mcp
 		} else {
 			status = "error"
 			errMsg = err.Error()
+			slog.WarnContext(ctx, "Database ping failed", "connection", database.Name(), "err", err)
 		}
 	}
 
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"td_go_mcp/internal/db"
+	"td_go_mcp/internal/tools"
+)
+
+func TestMCPTextKeepsResultsWithTransform(t *testing.T) {
+	run := &toolRun{
+		SQL:         "SELECT 1;\n\nSELECT 2",
+		Results:     map[string][]map[string]interface{}{"first": {{"n": 1}}, "second": {{"n": 2}}},
+		Output:      int64(2),
+		Transformed: true,
+	}
+	text, err := run.mcpText()
+	if err != nil {
+		t.Fatalf("mcpText: %v", err)
+	}
+	var got map[string]any
+	if err := json.Unmarshal([]byte(text), &got); err != nil {
+		t.Fatalf("expected JSON, got %q", text)
+	}
+	results, _ := got["results"].(map[string]any)
+	if got["result"] != float64(2) || len(results) != 2 || got["sql"] != run.SQL {
+		t.Fatalf("expected result, results and sql, got %s", text)
+	}
+}
+
+// fakeDriver is a database/sql driver that answers a query with the rows
+// registered for its longest matching prefix and records what it ran
+type fakeDriver struct {
+	mu      sync.Mutex
+	rows    map[string]*fakeRows
+	queries []string
+}
+
+var (
+	fake         = &fakeDriver{}
+	registerFake sync.Once
+)
+
+func (d *fakeDriver) Open(string) (driver.Conn, error) { return fakeConn{d}, nil }
+
+type fakeConn struct{ d *fakeDriver }
+
+func (fakeConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (fakeConn) Close() error                        { return nil }
+func (fakeConn) Begin() (driver.Tx, error)           { return nil, errors.New("not supported") }
+
+func (c fakeConn) ExecContext(context.Context, string, []driver.NamedValue) (driver.Result, error) {
+	return driver.RowsAffected(0), nil
+}
+
+func (c fakeConn) QueryContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Rows, error) {
+	c.d.mu.Lock()
+	defer c.d.mu.Unlock()
+	c.d.queries = append(c.d.queries, query)
+	match := ""
+	for prefix := range c.d.rows {
+		if strings.HasPrefix(query, prefix) && len(prefix) > len(match) {
+			match = prefix
+		}
+	}
+	if match == "" {
+		return nil, errors.New("unexpected query: " + query)
+	}
+	rows := *c.d.rows[match]
+	return &rows, nil
+}
+
+type fakeRows struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
+
+func TestStatementsReadColumnsOfEarlierResults(t *testing.T) {
+	registerFake.Do(func() { sql.Register("fake", fake) })
+	fake.rows = map[string]*fakeRows{
+		"SELECT user_type, sessions": {columns: []string{"user_type", "sessions"}, values: [][]driver.Value{{"admin", int64(3)}}},
+		"SELECT session_id":          {columns: []string{"session_id"}, values: [][]driver.Value{{"s1"}, {"s2"}}},
+	}
+	fake.queries = nil
+	conn, err := db.Connect(&db.Config{Name: "fake", Driver: "fake", DSN: "test"})
+	if err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	defer conn.Close()
+
+	toolDef := tools.ToolDefinition{
+		Name: "busiest_sessions",
+		Statements: []tools.Statement{
+			{Name: "busiest", SQL: "SELECT user_type, sessions FROM session_counts"},
+			{Name: "sessions", SQL: "SELECT session_id FROM user_sessions WHERE user_type = '{{escape .results.busiest.user_type}}'"},
+		},
+	}
+	processor, err := tools.NewSQLProcessor(toolDef)
+	if err != nil {
+		t.Fatalf("NewSQLProcessor: %v", err)
+	}
+	defer func(procs map[string]*tools.SQLProcessor, c *db.DB) {
+		processors, database = procs, c
+	}(processors, database)
+	processors = map[string]*tools.SQLProcessor{toolDef.Name: processor}
+	database = conn
+
+	run, err := runYAMLTool(context.Background(), toolDef, map[string]any{}, nil)
+	if err != nil {
+		t.Fatalf("runYAMLTool: %v", err)
+	}
+	want := "SELECT session_id FROM user_sessions WHERE user_type = 'admin'"
+	if !strings.Contains(strings.Join(fake.queries, "\n"), want) {
+		t.Fatalf("expected %q to run, ran %q", want, fake.queries)
+	}
+	if len(run.Results["sessions"]) != 2 || !strings.HasSuffix(run.SQL, want) {
+		t.Fatalf("expected both statements' results and SQL, got %v and %q", run.Results, run.SQL)
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+
+	"td_go_mcp/internal/tracing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracingMiddleware starts the root span of a tool call, continuing the
+// client's trace when it sends traceparent in the request _meta. Handler
+// stages and database spans are children of this span.
+func tracingMiddleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		ctx = tracing.ContextFromMeta(ctx, req.Params.Meta)
+		ctx, span := tracing.Tracer().Start(ctx, "tools/call "+req.Params.Name,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(tracing.AttrTool.String(req.Params.Name), tracing.AttrConnection.String(connectionName())),
+		)
+		defer span.End()
+
+		result, err := next(ctx, req)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		} else if result != nil && result.IsError {
+			span.SetStatus(codes.Error, "tool returned an error result")
+		}
+		return result, err
+	}
+}
@@ -0,0 +1,170 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"td_go_mcp/internal/rotate"
+)
+
+// Redacted replaces secret argument values in audit records
+const Redacted = "[REDACTED]"
+
+// Outcome values recorded for each call
+const (
+	OutcomeOK       = "ok"
+	OutcomeError    = "error"
+	OutcomeRejected = "rejected"
+	OutcomePreview  = "preview"
+	OutcomeTestData = "test_data"
+	// OutcomeThrottled means a rate or concurrency limit turned the call away
+	OutcomeThrottled = "throttled"
+)
+
+// Record is one line of the audit stream
+type Record struct {
+	Timestamp  time.Time      `json:"timestamp"`
+	SessionID  string         `json:"session_id,omitempty"`
+	Client     string         `json:"client,omitempty"`
+	Principal  string         `json:"principal,omitempty"`
+	AuthMethod string         `json:"auth_method,omitempty"`
+	Tool       string         `json:"tool"`
+	Pipeline   string         `json:"pipeline,omitempty"`
+	Arguments  map[string]any `json:"arguments,omitempty"`
+	SQL        string         `json:"sql,omitempty"`
+	Connection string         `json:"connection,omitempty"`
+	Rows       int            `json:"rows"`
+	Cached     bool           `json:"cached,omitempty"`
+	DurationMS int64          `json:"duration_ms"`
+	Outcome    string         `json:"outcome"`
+	Error      string         `json:"error,omitempty"`
+}
+
+// Config selects where audit records are written. Destination is a file
+// path, "stdout", "stderr" or "off".
+type Config struct {
+	Destination string `yaml:"destination"`
+	MaxSizeMB   int    `yaml:"max_size_mb"`
+	MaxAgeDays  int    `yaml:"max_age_days"`
+	MaxBackups  int    `yaml:"max_backups"`
+}
+
+// Logger writes audit records as JSON lines. A nil *Logger discards records.
+type Logger struct {
+	mu     sync.Mutex
+	w      io.Writer
+	closer io.Closer
+}
+
+// New creates a Logger for cfg, returning nil when auditing is off
+func New(cfg Config) (*Logger, error) {
+	switch strings.ToLower(cfg.Destination) {
+	case "", "off", "none":
+		return nil, nil
+	case "stdout":
+		return &Logger{w: os.Stdout}, nil
+	case "stderr":
+		return &Logger{w: os.Stderr}, nil
+	}
+	f, err := rotate.Open(cfg.Destination, rotate.Options{
+		MaxSizeMB:  cfg.MaxSizeMB,
+		MaxAgeDays: cfg.MaxAgeDays,
+		MaxBackups: cfg.MaxBackups,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	return &Logger{w: f, closer: f}, nil
+}
+
+// NewWriter creates a Logger that writes to w
+func NewWriter(w io.Writer) *Logger {
+	return &Logger{w: w}
+}
+
+// Log writes rec as a single JSON line, stamping it if Timestamp is zero
+func (l *Logger) Log(rec Record) error {
+	if l == nil {
+		return nil
+	}
+	if rec.Timestamp.IsZero() {
+		rec.Timestamp = time.Now().UTC()
+	}
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("failed to marshal audit record: %w", err)
+	}
+	line = append(line, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, err = l.w.Write(line)
+	return err
+}
+
+// Close closes the underlying file, if any
+func (l *Logger) Close() error {
+	if l == nil || l.closer == nil {
+		return nil
+	}
+	return l.closer.Close()
+}
+
+// secretNameParts mark argument names whose values are always redacted
+var secretNameParts = []string{"password", "passwd", "pwd", "secret", "token", "apikey", "api_key", "credential", "authorization"}
+
+// IsSecretName reports whether an argument name looks like it holds a secret
+func IsSecretName(name string) bool {
+	lower := strings.ToLower(name)
+	for _, part := range secretNameParts {
+		if strings.Contains(lower, part) {
+			return true
+		}
+	}
+	return false
+}
+
+// RedactArguments returns a copy of args with secret values replaced. An
+// argument is secret if its name is listed in secrets or IsSecretName matches.
+func RedactArguments(args map[string]any, secrets []string) map[string]any {
+	if args == nil {
+		return nil
+	}
+	redacted := make(map[string]any, len(args))
+	for name, value := range args {
+		if IsSecretName(name) || containsFold(secrets, name) {
+			redacted[name] = Redacted
+		} else {
+			redacted[name] = value
+		}
+	}
+	return redacted
+}
+
+// RedactSQL replaces the string values of secret arguments wherever they
+// appear in sql
+func RedactSQL(sql string, args map[string]any, secrets []string) string {
+	for name, value := range args {
+		if !IsSecretName(name) && !containsFold(secrets, name) {
+			continue
+		}
+		if s, ok := value.(string); ok && s != "" {
+			sql = strings.ReplaceAll(sql, s, Redacted)
+		}
+	}
+	return sql
+}
+
+func containsFold(slice []string, item string) bool {
+	for _, s := range slice {
+		if strings.EqualFold(s, item) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,62 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestLoggerWritesRedactedJSONLines(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewWriter(&buf)
+
+	args := map[string]any{"user_id": "42", "api_token": "abc123", "pin": "9999"}
+	secrets := []string{"pin"}
+	rec := Record{
+		SessionID: "session-1",
+		Client:    "vscode",
+		Tool:      "get_user_by_id",
+		Arguments: RedactArguments(args, secrets),
+		SQL:       RedactSQL("SELECT * FROM users WHERE user_id = '42' AND pin = '9999'", args, secrets),
+		Rows:      1,
+		Outcome:   OutcomeOK,
+	}
+	for i := 0; i < 2; i++ {
+		if err := logger.Log(rec); err != nil {
+			t.Fatalf("Log failed: %v", err)
+		}
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %d", len(lines))
+	}
+
+	var got Record
+	if err := json.Unmarshal([]byte(lines[0]), &got); err != nil {
+		t.Fatalf("failed to unmarshal record: %v", err)
+	}
+	if got.Timestamp.IsZero() {
+		t.Error("expected timestamp to be set")
+	}
+	if got.Arguments["api_token"] != Redacted || got.Arguments["pin"] != Redacted {
+		t.Errorf("expected secrets to be redacted, got %v", got.Arguments)
+	}
+	if got.Arguments["user_id"] != "42" {
+		t.Errorf("expected user_id to be kept, got %v", got.Arguments["user_id"])
+	}
+	if strings.Contains(got.SQL, "9999") {
+		t.Errorf("expected secret to be removed from SQL, got %q", got.SQL)
+	}
+}
+
+func TestNilLoggerDiscards(t *testing.T) {
+	var logger *Logger
+	if err := logger.Log(Record{Tool: "x"}); err != nil {
+		t.Fatalf("nil logger should discard, got %v", err)
+	}
+	if l, err := New(Config{Destination: "off"}); l != nil || err != nil {
+		t.Fatalf("expected nil logger for off destination, got %v, %v", l, err)
+	}
+}
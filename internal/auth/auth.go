@@ -0,0 +1,298 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+	"gopkg.in/yaml.v3"
+)
+
+// MetadataPath is where the OAuth protected-resource metadata is served
+const MetadataPath = "/.well-known/oauth-protected-resource"
+
+// Authentication methods recorded on a Principal
+const (
+	MethodAPIKey = "api_key"
+	MethodJWT    = "jwt"
+	// MethodEnv names a caller configured in the environment (stdio mode)
+	MethodEnv = "env"
+)
+
+var (
+	// ErrNoCredentials means the request carried no API key or bearer token
+	ErrNoCredentials = errors.New("missing credentials")
+	// ErrInvalidCredentials means the credentials were present but not accepted
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+// Config controls HTTP authentication. API keys, HMAC-signed JWTs and JWTs
+// signed by a key in a local JWKS file can be enabled together.
+type Config struct {
+	Enabled        bool   `yaml:"enabled"`
+	APIKeysFile    string `yaml:"api_keys_file"`
+	HMACSecretFile string `yaml:"hmac_secret_file"`
+	JWKSFile       string `yaml:"jwks_file"`
+	Issuer         string `yaml:"issuer"`
+	Audience       string `yaml:"audience"`
+	// RolesClaim names the JWT claim holding the caller's roles (a list or
+	// a space-separated string)
+	RolesClaim string `yaml:"roles_claim"`
+	// Resource is this server's canonical URL for the protected-resource
+	// metadata; empty derives it from the request
+	Resource             string   `yaml:"resource"`
+	AuthorizationServers []string `yaml:"authorization_servers"`
+	// PublicPaths are served without credentials
+	PublicPaths []string `yaml:"public_paths"`
+}
+
+// Principal is an authenticated caller
+type Principal struct {
+	Subject string   `json:"subject"`
+	Roles   []string `json:"roles,omitempty"`
+	Method  string   `json:"method"`
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns a context carrying p
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFromContext returns the caller attached by the middleware, or nil
+func PrincipalFromContext(ctx context.Context) *Principal {
+	p, _ := ctx.Value(principalKey{}).(*Principal)
+	return p
+}
+
+// apiKey is one entry of the API keys file. Keys are stored as the hex
+// SHA-256 of the key so the file does not hold usable secrets.
+type apiKey struct {
+	Name      string   `yaml:"name"`
+	KeySHA256 string   `yaml:"key_sha256"`
+	Roles     []string `yaml:"roles"`
+}
+
+// Authenticator validates API keys and bearer tokens
+type Authenticator struct {
+	cfg        Config
+	apiKeys    []apiKey
+	hmacSecret []byte
+	jwks       map[string]any // kid -> public key
+	parser     *jwt.Parser
+}
+
+// New loads the key files named by cfg. It returns nil when auth is disabled.
+func New(cfg Config) (*Authenticator, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	if cfg.RolesClaim == "" {
+		cfg.RolesClaim = "roles"
+	}
+	a := &Authenticator{cfg: cfg}
+
+	if cfg.APIKeysFile != "" {
+		data, err := os.ReadFile(cfg.APIKeysFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read API keys file: %w", err)
+		}
+		var file struct {
+			Keys []apiKey `yaml:"keys"`
+		}
+		if err := yaml.Unmarshal(data, &file); err != nil {
+			return nil, fmt.Errorf("failed to parse API keys file: %w", err)
+		}
+		for _, k := range file.Keys {
+			if k.Name == "" || len(k.KeySHA256) != sha256.Size*2 {
+				return nil, fmt.Errorf("API key %q: name and a hex key_sha256 are required", k.Name)
+			}
+			k.KeySHA256 = strings.ToLower(k.KeySHA256)
+			a.apiKeys = append(a.apiKeys, k)
+		}
+	}
+	if cfg.HMACSecretFile != "" {
+		data, err := os.ReadFile(cfg.HMACSecretFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read HMAC secret file: %w", err)
+		}
+		a.hmacSecret = []byte(strings.TrimSpace(string(data)))
+		if len(a.hmacSecret) < 32 {
+			return nil, fmt.Errorf("HMAC secret must be at least 32 bytes")
+		}
+	}
+	if cfg.JWKSFile != "" {
+		keys, err := loadJWKS(cfg.JWKSFile)
+		if err != nil {
+			return nil, err
+		}
+		a.jwks = keys
+	}
+	if len(a.apiKeys) == 0 && a.hmacSecret == nil && len(a.jwks) == 0 {
+		return nil, fmt.Errorf("auth is enabled but no API keys, HMAC secret or JWKS file is configured")
+	}
+
+	var methods []string
+	if a.hmacSecret != nil {
+		methods = append(methods, "HS256", "HS384", "HS512")
+	}
+	if len(a.jwks) > 0 {
+		methods = append(methods, "RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA")
+	}
+	opts := []jwt.ParserOption{jwt.WithValidMethods(methods), jwt.WithExpirationRequired()}
+	if cfg.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(cfg.Issuer))
+	}
+	if cfg.Audience != "" {
+		opts = append(opts, jwt.WithAudience(cfg.Audience))
+	}
+	a.parser = jwt.NewParser(opts...)
+	return a, nil
+}
+
+// Authenticate identifies the caller from an X-API-Key header or an
+// Authorization: Bearer header holding an API key or a JWT
+func (a *Authenticator) Authenticate(r *http.Request) (*Principal, error) {
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return a.checkAPIKey(key)
+	}
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(token) == "" {
+		return nil, ErrNoCredentials
+	}
+	token = strings.TrimSpace(token)
+	if strings.Count(token, ".") == 2 && (a.hmacSecret != nil || len(a.jwks) > 0) {
+		return a.checkJWT(token)
+	}
+	return a.checkAPIKey(token)
+}
+
+func (a *Authenticator) checkAPIKey(key string) (*Principal, error) {
+	sum := sha256.Sum256([]byte(key))
+	got := hex.EncodeToString(sum[:])
+	for _, k := range a.apiKeys {
+		if subtle.ConstantTimeCompare([]byte(got), []byte(k.KeySHA256)) == 1 {
+			return &Principal{Subject: k.Name, Roles: k.Roles, Method: MethodAPIKey}, nil
+		}
+	}
+	return nil, ErrInvalidCredentials
+}
+
+func (a *Authenticator) checkJWT(raw string) (*Principal, error) {
+	claims := jwt.MapClaims{}
+	_, err := a.parser.ParseWithClaims(raw, claims, a.keyFor)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+	}
+	sub, _ := claims.GetSubject()
+	if sub == "" {
+		return nil, fmt.Errorf("%w: token has no subject", ErrInvalidCredentials)
+	}
+	return &Principal{Subject: sub, Roles: rolesFrom(claims[a.cfg.RolesClaim]), Method: MethodJWT}, nil
+}
+
+// keyFor picks the verification key for a token by algorithm and kid
+func (a *Authenticator) keyFor(token *jwt.Token) (any, error) {
+	if _, ok := token.Method.(*jwt.SigningMethodHMAC); ok {
+		if a.hmacSecret == nil {
+			return nil, fmt.Errorf("HMAC tokens are not accepted")
+		}
+		return a.hmacSecret, nil
+	}
+	kid, _ := token.Header["kid"].(string)
+	if key, ok := a.jwks[kid]; ok {
+		return key, nil
+	}
+	if kid == "" && len(a.jwks) == 1 {
+		for _, key := range a.jwks {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+func rolesFrom(v any) []string {
+	switch roles := v.(type) {
+	case string:
+		return strings.Fields(roles)
+	case []any:
+		var result []string
+		for _, r := range roles {
+			if s, ok := r.(string); ok {
+				result = append(result, s)
+			}
+		}
+		return result
+	}
+	return nil
+}
+
+// Middleware rejects requests without valid credentials with 401 and a
+// WWW-Authenticate header pointing at the protected-resource metadata, and
+// attaches the Principal to the request context otherwise
+func (a *Authenticator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == MetadataPath || a.isPublic(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		p, err := a.Authenticate(r)
+		if err != nil {
+			challenge := fmt.Sprintf(`Bearer resource_metadata="%s"`, a.resource(r)+MetadataPath)
+			if !errors.Is(err, ErrNoCredentials) {
+				challenge += `, error="invalid_token"`
+			}
+			w.Header().Set("WWW-Authenticate", challenge)
+			http.Error(w, "unauthorized: "+err.Error(), http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), p)))
+	})
+}
+
+func (a *Authenticator) isPublic(path string) bool {
+	for _, p := range a.cfg.PublicPaths {
+		if path == p {
+			return true
+		}
+	}
+	return false
+}
+
+// MetadataHandler serves the OAuth 2.0 protected-resource metadata (RFC 9728)
+// that MCP clients use to discover the authorization server
+func (a *Authenticator) MetadataHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		metadata := map[string]any{
+			"resource":                 a.resource(r),
+			"bearer_methods_supported": []string{"header"},
+			"resource_name":            "td-go-mcp",
+		}
+		if len(a.cfg.AuthorizationServers) > 0 {
+			metadata["authorization_servers"] = a.cfg.AuthorizationServers
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(metadata)
+	})
+}
+
+// resource returns the configured resource URL or one derived from the request
+func (a *Authenticator) resource(r *http.Request) string {
+	if a.cfg.Resource != "" {
+		return strings.TrimSuffix(a.cfg.Resource, "/")
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
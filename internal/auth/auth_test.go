@@ -0,0 +1,186 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const testSecret = "0123456789abcdef0123456789abcdef"
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func newTestAuthenticator(t *testing.T, rsaKey *rsa.PrivateKey) *Authenticator {
+	t.Helper()
+	sum := sha256.Sum256([]byte("dashboard-key"))
+	keys := writeFile(t, "keys.yaml", "keys:\n  - name: dashboards\n    key_sha256: "+hex.EncodeToString(sum[:])+"\n    roles: [analyst]\n")
+	secret := writeFile(t, "secret", testSecret+"\n")
+	jwks, _ := json.Marshal(map[string]any{"keys": []any{map[string]any{
+		"kty": "RSA",
+		"kid": "k1",
+		"use": "sig",
+		"n":   base64.RawURLEncoding.EncodeToString(rsaKey.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(rsaKey.E)).Bytes()),
+	}}})
+	a, err := New(Config{
+		Enabled:        true,
+		APIKeysFile:    keys,
+		HMACSecretFile: secret,
+		JWKSFile:       writeFile(t, "jwks.json", string(jwks)),
+		Issuer:         "https://idp.example.com",
+		Audience:       "td-go-mcp",
+		PublicPaths:    []string{"/livez"},
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	return a
+}
+
+func TestAuthenticate(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := newTestAuthenticator(t, rsaKey)
+
+	claims := func(mutate func(jwt.MapClaims)) jwt.MapClaims {
+		c := jwt.MapClaims{
+			"sub":   "alice",
+			"iss":   "https://idp.example.com",
+			"aud":   "td-go-mcp",
+			"exp":   time.Now().Add(time.Hour).Unix(),
+			"roles": []string{"hr", "analyst"},
+		}
+		if mutate != nil {
+			mutate(c)
+		}
+		return c
+	}
+	hmacToken := func(c jwt.MapClaims) string {
+		s, err := jwt.NewWithClaims(jwt.SigningMethodHS256, c).SignedString([]byte(testSecret))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	rsaToken := jwt.NewWithClaims(jwt.SigningMethodRS256, claims(nil))
+	rsaToken.Header["kid"] = "k1"
+	signedRSA, err := rsaToken.SignedString(rsaKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		header  string
+		value   string
+		subject string
+		roles   int
+		wantErr error
+	}{
+		{"api key header", "X-API-Key", "dashboard-key", "dashboards", 1, nil},
+		{"api key bearer", "Authorization", "Bearer dashboard-key", "dashboards", 1, nil},
+		{"wrong api key", "X-API-Key", "nope", "", 0, ErrInvalidCredentials},
+		{"hmac jwt", "Authorization", "Bearer " + hmacToken(claims(nil)), "alice", 2, nil},
+		{"jwks jwt", "Authorization", "Bearer " + signedRSA, "alice", 2, nil},
+		{"expired jwt", "Authorization", "Bearer " + hmacToken(claims(func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Minute).Unix() })), "", 0, ErrInvalidCredentials},
+		{"wrong issuer", "Authorization", "Bearer " + hmacToken(claims(func(c jwt.MapClaims) { c["iss"] = "https://evil.example.com" })), "", 0, ErrInvalidCredentials},
+		{"wrong audience", "Authorization", "Bearer " + hmacToken(claims(func(c jwt.MapClaims) { c["aud"] = "other" })), "", 0, ErrInvalidCredentials},
+		{"no credentials", "", "", "", 0, ErrNoCredentials},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/mcp", nil)
+			if tt.header != "" {
+				r.Header.Set(tt.header, tt.value)
+			}
+			p, err := a.Authenticate(r)
+			if tt.wantErr != nil {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr.Error()) {
+					t.Fatalf("expected %v, got %v (%+v)", tt.wantErr, err, p)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if p.Subject != tt.subject || len(p.Roles) != tt.roles {
+				t.Fatalf("unexpected principal %+v", p)
+			}
+		})
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := newTestAuthenticator(t, rsaKey)
+
+	var seen *Principal
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		seen = PrincipalFromContext(r.Context())
+	})
+	mux.Handle(MetadataPath, a.MetadataHandler())
+	h := a.Middleware(mux)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "http://mcp.example.com/mcp", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("WWW-Authenticate"); !strings.Contains(got, `resource_metadata="http://mcp.example.com/.well-known/oauth-protected-resource"`) {
+		t.Fatalf("unexpected WWW-Authenticate %q", got)
+	}
+
+	rec = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/mcp", nil)
+	req.Header.Set("X-API-Key", "dashboard-key")
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || seen == nil || seen.Subject != "dashboards" || seen.Method != MethodAPIKey {
+		t.Fatalf("expected authenticated request, got %d %+v", rec.Code, seen)
+	}
+
+	for _, path := range []string{"/livez", MetadataPath} {
+		rec = httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected %s to be public, got %d", path, rec.Code)
+		}
+	}
+	if !strings.Contains(rec.Body.String(), `"resource":"http://example.com"`) {
+		t.Fatalf("unexpected metadata %s", rec.Body.String())
+	}
+}
+
+func TestNewRequiresCredentialsSource(t *testing.T) {
+	if a, err := New(Config{}); a != nil || err != nil {
+		t.Fatalf("disabled auth should return nil, nil; got %v, %v", a, err)
+	}
+	if _, err := New(Config{Enabled: true}); err == nil {
+		t.Fatal("expected error when auth is enabled without keys")
+	}
+}
@@ -0,0 +1,108 @@
+package authz
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"td_go_mcp/internal/auth"
+)
+
+// ErrForbidden means the caller may not use the tool
+var ErrForbidden = errors.New("access denied")
+
+// Rule restricts a tool to listed users and roles. An empty rule allows everyone.
+type Rule struct {
+	AllowedRoles []string `yaml:"allowed_roles"`
+	AllowedUsers []string `yaml:"allowed_users"`
+}
+
+// Open reports whether the rule allows every caller
+func (r Rule) Open() bool {
+	return len(r.AllowedRoles) == 0 && len(r.AllowedUsers) == 0
+}
+
+// Policy maps caller identities to roles, and can override the rule of any
+// tool (including built-in tools that have no YAML definition)
+type Policy struct {
+	// Users maps a principal's subject to extra roles
+	Users map[string][]string `yaml:"users"`
+	// DefaultRoles are granted to every authenticated caller
+	DefaultRoles []string `yaml:"default_roles"`
+	// Tools overrides the allowed_roles/allowed_users of a tool by name
+	Tools map[string]Rule `yaml:"tools"`
+}
+
+// LoadPolicy reads a policy file. An empty path returns an empty policy.
+func LoadPolicy(path string) (*Policy, error) {
+	policy := &Policy{}
+	if path == "" {
+		return policy, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read policy file: %w", err)
+	}
+	if err := yaml.Unmarshal(data, policy); err != nil {
+		return nil, fmt.Errorf("failed to parse policy file: %w", err)
+	}
+	return policy, nil
+}
+
+// Roles returns the caller's roles: those from its credentials, plus those
+// the policy grants to its subject and to every authenticated caller
+func (p *Policy) Roles(principal *auth.Principal) []string {
+	if principal == nil {
+		return nil
+	}
+	roles := append([]string(nil), principal.Roles...)
+	if p != nil {
+		roles = append(roles, p.Users[principal.Subject]...)
+		roles = append(roles, p.DefaultRoles...)
+	}
+	return roles
+}
+
+// RuleFor returns the policy's override for tool, or fallback
+func (p *Policy) RuleFor(tool string, fallback Rule) Rule {
+	if p != nil {
+		if rule, ok := p.Tools[tool]; ok {
+			return rule
+		}
+	}
+	return fallback
+}
+
+// Check returns nil if principal may use a tool guarded by rule, or an
+// ErrForbidden error saying what the tool requires
+func (p *Policy) Check(tool string, rule Rule, principal *auth.Principal) error {
+	if rule.Open() {
+		return nil
+	}
+	if principal == nil {
+		return fmt.Errorf("%w: tool %s requires an authenticated caller", ErrForbidden, tool)
+	}
+	for _, user := range rule.AllowedUsers {
+		if user == principal.Subject {
+			return nil
+		}
+	}
+	for _, role := range p.Roles(principal) {
+		for _, allowed := range rule.AllowedRoles {
+			if role == allowed {
+				return nil
+			}
+		}
+	}
+	var needs []string
+	if len(rule.AllowedRoles) > 0 {
+		needs = append(needs, "one of roles "+strings.Join(rule.AllowedRoles, ", "))
+	}
+	if len(rule.AllowedUsers) > 0 {
+		needs = append(needs, "a listed user")
+	}
+	return fmt.Errorf("%w: %s may not call %s (requires %s)", ErrForbidden, principal.Subject, tool, strings.Join(needs, " or "))
+}
@@ -0,0 +1,76 @@
+package authz
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"td_go_mcp/internal/auth"
+)
+
+func TestCheck(t *testing.T) {
+	policy := &Policy{
+		Users:        map[string][]string{"alice": {"analyst"}},
+		DefaultRoles: []string{"reader"},
+	}
+	rule := Rule{AllowedRoles: []string{"analyst", "admin"}, AllowedUsers: []string{"carol"}}
+
+	cases := []struct {
+		name      string
+		rule      Rule
+		principal *auth.Principal
+		allowed   bool
+	}{
+		{"open rule, anonymous", Rule{}, nil, true},
+		{"anonymous", rule, nil, false},
+		{"role from credentials", rule, &auth.Principal{Subject: "bob", Roles: []string{"admin"}}, true},
+		{"role from policy", rule, &auth.Principal{Subject: "alice"}, true},
+		{"listed user", rule, &auth.Principal{Subject: "carol"}, true},
+		{"no matching role", rule, &auth.Principal{Subject: "dave", Roles: []string{"guest"}}, false},
+		{"default role", Rule{AllowedRoles: []string{"reader"}}, &auth.Principal{Subject: "dave"}, true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := policy.Check("get_user_by_id", tc.rule, tc.principal)
+			if tc.allowed && err != nil {
+				t.Fatalf("expected access, got %v", err)
+			}
+			if !tc.allowed && !errors.Is(err, ErrForbidden) {
+				t.Fatalf("expected ErrForbidden, got %v", err)
+			}
+		})
+	}
+}
+
+func TestLoadPolicy(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	data := `
+users:
+  alice: [analyst]
+tools:
+  run_sql:
+    allowed_roles: [dba]
+`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatalf("write policy: %v", err)
+	}
+	policy, err := LoadPolicy(path)
+	if err != nil {
+		t.Fatalf("LoadPolicy: %v", err)
+	}
+	if got := policy.RuleFor("run_sql", Rule{}); len(got.AllowedRoles) != 1 || got.AllowedRoles[0] != "dba" {
+		t.Fatalf("expected run_sql override, got %+v", got)
+	}
+	fallback := Rule{AllowedUsers: []string{"bob"}}
+	if got := policy.RuleFor("count_records", fallback); len(got.AllowedUsers) != 1 {
+		t.Fatalf("expected fallback rule, got %+v", got)
+	}
+	if err := policy.Check("run_sql", policy.RuleFor("run_sql", Rule{}), &auth.Principal{Subject: "alice"}); err == nil {
+		t.Fatal("expected analyst to be denied run_sql")
+	}
+
+	if _, err := LoadPolicy(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Fatal("expected error for missing policy file")
+	}
+}
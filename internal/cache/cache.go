@@ -0,0 +1,91 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// Entry is a cached value and when it was stored
+type Entry struct {
+	Value  any
+	Stored time.Time
+	size   int64
+	expiry time.Time
+	key    string
+}
+
+// Cache is an LRU cache with per-entry TTLs, capped by entry count and by
+// the total of the sizes callers report for their values. It is safe for
+// concurrent use.
+type Cache struct {
+	maxEntries int
+	maxBytes   int64
+
+	mu    sync.Mutex
+	order *list.List // front is most recently used
+	items map[string]*list.Element
+	bytes int64
+	now   func() time.Time
+}
+
+// New creates a cache. Zero limits are unbounded.
+func New(maxEntries int, maxBytes int64) *Cache {
+	return &Cache{
+		maxEntries: maxEntries,
+		maxBytes:   maxBytes,
+		order:      list.New(),
+		items:      map[string]*list.Element{},
+		now:        time.Now,
+	}
+}
+
+// Get returns the live entry for key
+func (c *Cache) Get(key string) (Entry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[key]
+	if !ok {
+		return Entry{}, false
+	}
+	entry := el.Value.(*Entry)
+	if !c.now().Before(entry.expiry) {
+		c.remove(el)
+		return Entry{}, false
+	}
+	c.order.MoveToFront(el)
+	return *entry, true
+}
+
+// Set stores value under key for ttl. size is the value's approximate
+// size in bytes; values larger than the byte cap are not stored.
+func (c *Cache) Set(key string, value any, size int64, ttl time.Duration) {
+	if ttl <= 0 || (c.maxBytes > 0 && size > c.maxBytes) {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[key]; ok {
+		c.remove(el)
+	}
+	now := c.now()
+	entry := &Entry{Value: value, Stored: now, size: size, expiry: now.Add(ttl), key: key}
+	c.items[key] = c.order.PushFront(entry)
+	c.bytes += size
+	for (c.maxEntries > 0 && c.order.Len() > c.maxEntries) || (c.maxBytes > 0 && c.bytes > c.maxBytes) {
+		c.remove(c.order.Back())
+	}
+}
+
+// Len returns the number of entries, including expired ones not yet evicted
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+func (c *Cache) remove(el *list.Element) {
+	entry := c.order.Remove(el).(*Entry)
+	delete(c.items, entry.key)
+	c.bytes -= entry.size
+}
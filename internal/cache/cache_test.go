@@ -0,0 +1,57 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExpiry(t *testing.T) {
+	c := New(10, 0)
+	now := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	c.now = func() time.Time { return now }
+
+	c.Set("a", 1, 1, time.Minute)
+	if entry, ok := c.Get("a"); !ok || entry.Value != 1 || !entry.Stored.Equal(now) {
+		t.Fatalf("expected live entry, got %+v %v", entry, ok)
+	}
+	now = now.Add(time.Minute)
+	if _, ok := c.Get("a"); ok {
+		t.Fatal("expected entry to expire after its TTL")
+	}
+	if c.Len() != 0 {
+		t.Fatalf("expected expired entry to be evicted, have %d", c.Len())
+	}
+	c.Set("b", 2, 1, 0)
+	if _, ok := c.Get("b"); ok {
+		t.Fatal("a zero TTL must not be cached")
+	}
+}
+
+func TestEviction(t *testing.T) {
+	c := New(2, 0)
+	c.Set("a", 1, 1, time.Hour)
+	c.Set("b", 2, 1, time.Hour)
+	c.Get("a") // b is now least recently used
+	c.Set("c", 3, 1, time.Hour)
+	if _, ok := c.Get("b"); ok {
+		t.Fatal("expected least recently used entry to be evicted")
+	}
+	if _, ok := c.Get("a"); !ok {
+		t.Fatal("expected recently used entry to survive")
+	}
+
+	bySize := New(0, 100)
+	bySize.Set("big", "x", 101, time.Hour)
+	if _, ok := bySize.Get("big"); ok {
+		t.Fatal("values over the byte cap must not be stored")
+	}
+	bySize.Set("a", "x", 60, time.Hour)
+	bySize.Set("b", "y", 60, time.Hour)
+	if _, ok := bySize.Get("a"); ok || bySize.Len() != 1 {
+		t.Fatalf("expected byte cap to evict the oldest entry, have %d", bySize.Len())
+	}
+	bySize.Set("b", "z", 30, time.Hour)
+	if entry, _ := bySize.Get("b"); entry.Value != "z" || bySize.bytes != 30 {
+		t.Fatalf("expected replaced entry to update size, got %v / %d bytes", entry.Value, bySize.bytes)
+	}
+}
@@ -0,0 +1,365 @@
+package config
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"td_go_mcp/internal/audit"
+	"td_go_mcp/internal/auth"
+	"td_go_mcp/internal/logging"
+	"td_go_mcp/internal/ratelimit"
+	"td_go_mcp/internal/tracing"
+)
+
+// Config holds server settings that are not specific to the database connection
+type Config struct {
+	// Transport is "stdio" (default) or "http"
+	Transport string          `yaml:"transport"`
+	HTTP      HTTPConfig      `yaml:"http"`
+	Admin     AdminConfig     `yaml:"admin"`
+	Auth      auth.Config     `yaml:"auth"`
+	Authz     AuthzConfig     `yaml:"authz"`
+	RunSQL    RunSQLConfig    `yaml:"run_sql"`
+	RateLimit RateLimitConfig `yaml:"rate_limit"`
+	Cache     CacheConfig     `yaml:"cache"`
+	Audit     audit.Config    `yaml:"audit"`
+	Logging   logging.Config  `yaml:"logging"`
+	Tracing   tracing.Config  `yaml:"tracing"`
+}
+
+// HTTPConfig controls the streamable HTTP / SSE transport
+type HTTPConfig struct {
+	Listen                 string `yaml:"listen"`
+	BasePath               string `yaml:"base_path"`
+	TLSCertFile            string `yaml:"tls_cert_file"`
+	TLSKeyFile             string `yaml:"tls_key_file"`
+	ShutdownTimeoutSeconds int    `yaml:"shutdown_timeout_seconds"`
+}
+
+// AdminConfig controls the admin/health endpoint. An empty Listen serves it
+// on the HTTP transport's listener in http mode and disables it in stdio mode.
+type AdminConfig struct {
+	Listen string `yaml:"listen"`
+}
+
+// AuthzConfig controls per-tool authorization. PolicyFile maps identities
+// to roles and overrides tool rules. Identity and IdentityRoles name the
+// caller in stdio mode, where there is no HTTP authentication.
+type AuthzConfig struct {
+	PolicyFile    string   `yaml:"policy_file"`
+	Identity      string   `yaml:"identity"`
+	IdentityRoles []string `yaml:"identity_roles"`
+}
+
+// RateLimitConfig limits tool calls across the server and per client
+// session. Tools add their own limits with rate_limit and max_concurrency.
+// Calls over a limit wait up to QueueTimeoutSeconds (at most MaxQueue per
+// limit) and are then rejected with a retry-after hint.
+type RateLimitConfig struct {
+	Global              ratelimit.Limit `yaml:"global"`
+	PerSession          ratelimit.Limit `yaml:"per_session"`
+	QueueTimeoutSeconds float64         `yaml:"queue_timeout_seconds"`
+	MaxQueue            int             `yaml:"max_queue"`
+}
+
+// CacheConfig caps the in-memory result cache used by tools with cache_ttl
+type CacheConfig struct {
+	MaxEntries int `yaml:"max_entries"`
+	MaxSizeMB  int `yaml:"max_size_mb"`
+}
+
+// RunSQLConfig controls the governed ad-hoc run_sql tool
+type RunSQLConfig struct {
+	Enabled             bool     `yaml:"enabled"`
+	AllowedDatabases    []string `yaml:"allowed_databases"`
+	AllowedTables       []string `yaml:"allowed_tables"`
+	MaxRows             int      `yaml:"max_rows"`
+	TimeoutSeconds      int      `yaml:"timeout_seconds"`
+	MaxEstimatedSeconds float64  `yaml:"max_estimated_seconds"`
+	MaxEstimatedRows    int64    `yaml:"max_estimated_rows"`
+	DenyProductJoins    bool     `yaml:"deny_product_joins"`
+}
+
+// Load reads config.yaml (or the file named by MCP_CONFIG) and applies
+// environment variable overrides
+func Load() *Config {
+	config := &Config{
+		Transport: "stdio",
+		HTTP: HTTPConfig{
+			Listen:                 ":8080",
+			BasePath:               "/",
+			ShutdownTimeoutSeconds: 15,
+		},
+		Auth: auth.Config{
+			RolesClaim:  "roles",
+			PublicPaths: []string{"/livez", "/healthz", "/readyz"},
+		},
+		RateLimit: RateLimitConfig{
+			Global:              ratelimit.Limit{MaxConcurrency: 16},
+			PerSession:          ratelimit.Limit{MaxConcurrency: 4},
+			QueueTimeoutSeconds: 10,
+			MaxQueue:            50,
+		},
+		Cache: CacheConfig{
+			MaxEntries: 1000,
+			MaxSizeMB:  64,
+		},
+		RunSQL: RunSQLConfig{
+			MaxRows:        1000,
+			TimeoutSeconds: 60,
+		},
+		Audit: audit.Config{
+			Destination: "audit/audit.jsonl",
+			MaxSizeMB:   100,
+			MaxAgeDays:  90,
+			MaxBackups:  10,
+		},
+		Tracing: tracing.Config{
+			Exporter:    "off",
+			ServiceName: "td-go-mcp",
+			SampleRatio: 1,
+		},
+		Logging: logging.Config{
+			Level:       "info",
+			Format:      "text",
+			Destination: "logging/td-go-mcp.log",
+			AddSource:   true,
+			MaxSizeMB:   50,
+			MaxAgeDays:  30,
+			MaxBackups:  5,
+		},
+	}
+
+	// Load from YAML file if present
+	yamlFile := "config.yaml"
+	if v := os.Getenv("MCP_CONFIG"); v != "" {
+		yamlFile = v
+	}
+	if _, err := os.Stat(yamlFile); err == nil {
+		data, err := os.ReadFile(yamlFile)
+		if err == nil {
+			_ = yaml.Unmarshal(data, config)
+		}
+	}
+
+	// Override from environment variables
+	if v := os.Getenv("MCP_TRANSPORT"); v != "" {
+		config.Transport = v
+	}
+	if v := os.Getenv("MCP_HTTP_LISTEN"); v != "" {
+		config.HTTP.Listen = v
+	}
+	if v := os.Getenv("MCP_HTTP_BASE_PATH"); v != "" {
+		config.HTTP.BasePath = v
+	}
+	if v := os.Getenv("MCP_TLS_CERT_FILE"); v != "" {
+		config.HTTP.TLSCertFile = v
+	}
+	if v := os.Getenv("MCP_TLS_KEY_FILE"); v != "" {
+		config.HTTP.TLSKeyFile = v
+	}
+	if v, ok := envInt("MCP_HTTP_SHUTDOWN_TIMEOUT_SECONDS"); ok {
+		config.HTTP.ShutdownTimeoutSeconds = v
+	}
+	if v := os.Getenv("MCP_ADMIN_LISTEN"); v != "" {
+		config.Admin.Listen = v
+	}
+
+	if v, ok := envBool("AUTH_ENABLED"); ok {
+		config.Auth.Enabled = v
+	}
+	if v := os.Getenv("AUTH_API_KEYS_FILE"); v != "" {
+		config.Auth.APIKeysFile = v
+	}
+	if v := os.Getenv("AUTH_HMAC_SECRET_FILE"); v != "" {
+		config.Auth.HMACSecretFile = v
+	}
+	if v := os.Getenv("AUTH_JWKS_FILE"); v != "" {
+		config.Auth.JWKSFile = v
+	}
+	if v := os.Getenv("AUTH_ISSUER"); v != "" {
+		config.Auth.Issuer = v
+	}
+	if v := os.Getenv("AUTH_AUDIENCE"); v != "" {
+		config.Auth.Audience = v
+	}
+	if v := os.Getenv("AUTH_RESOURCE"); v != "" {
+		config.Auth.Resource = v
+	}
+	if v := envList("AUTH_AUTHORIZATION_SERVERS"); v != nil {
+		config.Auth.AuthorizationServers = v
+	}
+	if v := os.Getenv("AUTHZ_POLICY_FILE"); v != "" {
+		config.Authz.PolicyFile = v
+	}
+	if v := os.Getenv("MCP_IDENTITY"); v != "" {
+		config.Authz.Identity = v
+	}
+	if v := envList("MCP_IDENTITY_ROLES"); v != nil {
+		config.Authz.IdentityRoles = v
+	}
+
+	if v := os.Getenv("RATE_LIMIT_GLOBAL"); v != "" {
+		config.RateLimit.Global.Rate = v
+	}
+	if v, ok := envInt("RATE_LIMIT_GLOBAL_CONCURRENCY"); ok {
+		config.RateLimit.Global.MaxConcurrency = v
+	}
+	if v := os.Getenv("RATE_LIMIT_PER_SESSION"); v != "" {
+		config.RateLimit.PerSession.Rate = v
+	}
+	if v, ok := envInt("RATE_LIMIT_PER_SESSION_CONCURRENCY"); ok {
+		config.RateLimit.PerSession.MaxConcurrency = v
+	}
+	if v, ok := envFloat("RATE_LIMIT_QUEUE_TIMEOUT_SECONDS"); ok {
+		config.RateLimit.QueueTimeoutSeconds = v
+	}
+	if v, ok := envInt("RATE_LIMIT_MAX_QUEUE"); ok {
+		config.RateLimit.MaxQueue = v
+	}
+
+	if v, ok := envInt("CACHE_MAX_ENTRIES"); ok {
+		config.Cache.MaxEntries = v
+	}
+	if v, ok := envInt("CACHE_MAX_SIZE_MB"); ok {
+		config.Cache.MaxSizeMB = v
+	}
+
+	if v, ok := envBool("RUN_SQL_ENABLED"); ok {
+		config.RunSQL.Enabled = v
+	}
+	if v := envList("RUN_SQL_ALLOWED_DATABASES"); v != nil {
+		config.RunSQL.AllowedDatabases = v
+	}
+	if v := envList("RUN_SQL_ALLOWED_TABLES"); v != nil {
+		config.RunSQL.AllowedTables = v
+	}
+	if v, ok := envInt("RUN_SQL_MAX_ROWS"); ok {
+		config.RunSQL.MaxRows = v
+	}
+	if v, ok := envInt("RUN_SQL_TIMEOUT_SECONDS"); ok {
+		config.RunSQL.TimeoutSeconds = v
+	}
+	if v, ok := envFloat("RUN_SQL_MAX_ESTIMATED_SECONDS"); ok {
+		config.RunSQL.MaxEstimatedSeconds = v
+	}
+	if v, ok := envInt("RUN_SQL_MAX_ESTIMATED_ROWS"); ok {
+		config.RunSQL.MaxEstimatedRows = int64(v)
+	}
+	if v, ok := envBool("RUN_SQL_DENY_PRODUCT_JOINS"); ok {
+		config.RunSQL.DenyProductJoins = v
+	}
+
+	if v := os.Getenv("AUDIT_LOG"); v != "" {
+		config.Audit.Destination = v
+	}
+	if v, ok := envInt("AUDIT_MAX_SIZE_MB"); ok {
+		config.Audit.MaxSizeMB = v
+	}
+	if v, ok := envInt("AUDIT_MAX_AGE_DAYS"); ok {
+		config.Audit.MaxAgeDays = v
+	}
+	if v, ok := envInt("AUDIT_MAX_BACKUPS"); ok {
+		config.Audit.MaxBackups = v
+	}
+
+	if v := os.Getenv("LOG_LEVEL"); v != "" {
+		config.Logging.Level = v
+	}
+	if v := os.Getenv("LOG_FORMAT"); v != "" {
+		config.Logging.Format = v
+	}
+	if v := os.Getenv("LOG_DESTINATION"); v != "" {
+		config.Logging.Destination = v
+	}
+	if v, ok := envBool("LOG_ADD_SOURCE"); ok {
+		config.Logging.AddSource = v
+	}
+	if v, ok := envInt("LOG_MAX_SIZE_MB"); ok {
+		config.Logging.MaxSizeMB = v
+	}
+	if v, ok := envInt("LOG_MAX_AGE_DAYS"); ok {
+		config.Logging.MaxAgeDays = v
+	}
+	if v, ok := envInt("LOG_MAX_BACKUPS"); ok {
+		config.Logging.MaxBackups = v
+	}
+	if v := os.Getenv("LOG_ROTATE_INTERVAL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			config.Logging.RotateInterval = d
+		}
+	}
+	// LOG_PACKAGE_LEVELS=internal/db=debug,cmd/mcp=warn
+	for _, item := range envList("LOG_PACKAGE_LEVELS") {
+		if pkg, level, ok := strings.Cut(item, "="); ok {
+			if config.Logging.PackageLevels == nil {
+				config.Logging.PackageLevels = make(map[string]string)
+			}
+			config.Logging.PackageLevels[strings.TrimSpace(pkg)] = strings.TrimSpace(level)
+		}
+	}
+
+	if v := os.Getenv("TRACING_EXPORTER"); v != "" {
+		config.Tracing.Exporter = v
+	}
+	if v := os.Getenv("TRACING_ENDPOINT"); v != "" {
+		config.Tracing.Endpoint = v
+	}
+	if v, ok := envBool("TRACING_INSECURE"); ok {
+		config.Tracing.Insecure = v
+	}
+	if v, ok := envFloat("TRACING_SAMPLE_RATIO"); ok {
+		config.Tracing.SampleRatio = v
+	}
+	if v := os.Getenv("OTEL_SERVICE_NAME"); v != "" {
+		config.Tracing.ServiceName = v
+	}
+
+	return config
+}
+
+func envBool(name string) (bool, bool) {
+	v := os.Getenv(name)
+	if v == "" {
+		return false, false
+	}
+	b, err := strconv.ParseBool(v)
+	return b, err == nil
+}
+
+func envInt(name string) (int, bool) {
+	v := os.Getenv(name)
+	if v == "" {
+		return 0, false
+	}
+	n, err := strconv.Atoi(v)
+	return n, err == nil
+}
+
+func envFloat(name string) (float64, bool) {
+	v := os.Getenv(name)
+	if v == "" {
+		return 0, false
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	return f, err == nil
+}
+
+// envList splits a comma-separated environment variable, returning nil when unset
+func envList(name string) []string {
+	v := os.Getenv(name)
+	if v == "" {
+		return nil
+	}
+	var items []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
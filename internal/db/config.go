@@ -1,17 +1,29 @@
 package db
 
 import (
+	"context"
 	"database/sql"
+	"database/sql/driver"
 	"fmt"
 	"os"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 	"gopkg.in/yaml.v3"
 
+	"td_go_mcp/internal/metrics"
+	"td_go_mcp/internal/tracing"
+
 	_ "github.com/alexbrainman/odbc"
 )
 
 type Config struct {
+	Name             string `yaml:"name"`
 	Driver           string `yaml:"driver"`
 	ConnectionString string `yaml:"connection_string"`
 	DSN              string `yaml:"dsn"`
@@ -20,6 +32,8 @@ type Config struct {
 	Database         string `yaml:"database"`
 	Username         string `yaml:"username"`
 	Password         string `yaml:"password"`
+	// Identity selects whose database account runs each caller's queries
+	Identity IdentityConfig `yaml:"identity"`
 }
 
 func LoadConfig() *Config {
@@ -38,6 +52,9 @@ func LoadConfig() *Config {
 	}
 
 	// Override from environment variables
+	if name := os.Getenv("DB_NAME"); name != "" {
+		config.Name = name
+	}
 	if driver := os.Getenv("DB_DRIVER"); driver != "" {
 		config.Driver = driver
 	}
@@ -62,6 +79,15 @@ func LoadConfig() *Config {
 	if password := os.Getenv("DB_PASSWORD"); password != "" {
 		config.Password = password
 	}
+	if mode := os.Getenv("DB_IDENTITY_MODE"); mode != "" {
+		config.Identity.Mode = mode
+	}
+	if file := os.Getenv("DB_CREDENTIALS_FILE"); file != "" {
+		config.Identity.CredentialsFile = file
+	}
+	if v, err := strconv.ParseBool(os.Getenv("DB_IDENTITY_ALLOW_SHARED")); err == nil {
+		config.Identity.AllowShared = v
+	}
 
 	return config
 }
@@ -99,9 +125,34 @@ func (c *Config) GetConnectionString() string {
 type DB struct {
 	conn   *sql.DB
 	config *Config
+	// credentials and users (per-caller pools) back the identity modes
+	credentials map[string]Credential
+	mu          sync.Mutex
+	users       map[string]*sql.DB
 }
 
 func Connect(config *Config) (*DB, error) {
+	var credentials map[string]Credential
+	switch config.Identity.Mode {
+	case "", IdentityShared:
+	case IdentityProxy, IdentityCredentials:
+		// Per-caller logins are spliced into an ODBC connection string
+		if config.Identity.Mode == IdentityCredentials && config.Driver != "odbc" {
+			return nil, fmt.Errorf("identity mode credentials requires the odbc driver, not %q", config.Driver)
+		}
+		if config.Identity.CredentialsFile != "" {
+			var err error
+			if credentials, err = LoadCredentials(config.Identity.CredentialsFile); err != nil {
+				return nil, err
+			}
+		} else if config.Identity.Mode == IdentityCredentials {
+			return nil, fmt.Errorf("identity mode credentials requires a credentials_file")
+		}
+
+	default:
+		return nil, fmt.Errorf("unknown identity mode %q (use shared, proxy or credentials)", config.Identity.Mode)
+	}
+
 	connStr := config.GetConnectionString()
 	conn, err := sql.Open(config.Driver, connStr)
 	if err != nil {
@@ -114,10 +165,21 @@ func Connect(config *Config) (*DB, error) {
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
-	return &DB{conn: conn, config: config}, nil
+	if err := metrics.RegisterDBStats(config.ConnectionName(), conn); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to register pool metrics: %w", err)
+	}
+
+	return &DB{conn: conn, config: config, credentials: credentials, users: map[string]*sql.DB{}}, nil
 }
 
 func (db *DB) Close() error {
+	db.mu.Lock()
+	for user, pool := range db.users {
+		pool.Close()
+		delete(db.users, user)
+	}
+	db.mu.Unlock()
 	if db.conn != nil {
 		return db.conn.Close()
 	}
@@ -125,19 +187,148 @@ func (db *DB) Close() error {
 }
 
 func (db *DB) ExecuteQuery(query string) ([]map[string]interface{}, error) {
-	rows, err := db.conn.Query(query)
+	results, _, err := db.ExecuteQueryContext(context.Background(), query, QueryOptions{})
+	return results, err
+}
+
+// Query phases reported through QueryOptions.Progress
+const (
+	PhaseConnecting = "connecting"
+	PhaseExecuting  = "executing"
+	PhaseFetching   = "fetching"
+)
+
+// progressRowInterval is how often Progress is called while fetching rows
+const progressRowInterval = 500
+
+// heartbeatInterval is how often Heartbeat is called while a statement runs
+var heartbeatInterval = 5 * time.Second
+
+// QueryOptions limits how ExecuteQueryContext runs a query
+type QueryOptions struct {
+	// MaxRows stops scanning after this many rows; 0 means unlimited
+	MaxRows int
+	// Progress, if set, is called as the query moves through its phases and
+	// every few hundred rows while fetching, with the rows read so far
+	Progress func(phase string, rows int)
+	// Heartbeat, if set, is called every few seconds while the database runs
+	// the statement and no rows have arrived yet, with the time since it was
+	// sent. It is never called concurrently with Progress.
+	Heartbeat func(elapsed time.Duration)
+	// QueryBand, if set, is applied to the session for the duration of the
+	// query with SET QUERY_BAND ... FOR SESSION and cleared afterwards. In
+	// proxy identity mode it also carries PROXYUSER.
+	QueryBand map[string]string
+}
+
+func (o QueryOptions) report(phase string, rows int) {
+	if o.Progress != nil {
+		o.Progress(phase, rows)
+	}
+}
+
+// heartbeat calls Heartbeat every heartbeatInterval until stop is called.
+// stop waits for a call in progress, so none overlaps what follows.
+func (o QueryOptions) heartbeat() (stop func()) {
+	if o.Heartbeat == nil {
+		return func() {}
+	}
+	start := time.Now()
+	done, finished := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(finished)
+		ticker := time.NewTicker(heartbeatInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				o.Heartbeat(time.Since(start))
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-finished
+	}
+}
+
+// ExecuteQueryContext runs query until ctx is done or opts.MaxRows rows have
+// been read. The returned bool reports whether the result was truncated.
+func (db *DB) ExecuteQueryContext(ctx context.Context, query string, opts QueryOptions) (results []map[string]interface{}, truncated bool, err error) {
+	ctx, done := db.startQuery(ctx, query)
+	defer func() { done(len(results), err) }()
+
+	// Child spans separate waiting for a pool connection from the database's own time
+	opts.report(PhaseConnecting, 0)
+	_, acquire := tracing.Tracer().Start(ctx, "db.acquire_connection")
+	conn, release, err := db.acquire(ctx, opts.QueryBand)
+	acquire.End()
+	if err != nil {
+		return nil, false, err
+	}
+	defer release()
+
+	return fetchRows(ctx, conn, query, opts)
+}
+
+// startQuery opens the db.query span and metrics for one statement. done
+// records the rows read and the error, if any.
+func (db *DB) startQuery(ctx context.Context, query string) (context.Context, func(rows int, err error)) {
+	name := db.Name()
+	metrics.ActiveQueries.WithLabelValues(name).Inc()
+	start := time.Now()
+	ctx, span := tracing.Tracer().Start(ctx, "db.query", trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		tracing.AttrConnection.String(name),
+		tracing.AttrStatementHash.String(tracing.StatementHash(query)),
+	))
+	return ctx, func(rows int, err error) {
+		metrics.ActiveQueries.WithLabelValues(name).Dec()
+		outcome := "ok"
+		if err != nil {
+			outcome = "error"
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		metrics.QueryDuration.WithLabelValues(name, outcome).Observe(time.Since(start).Seconds())
+		span.SetAttributes(tracing.AttrRows.Int(rows))
+		span.End()
+	}
+}
+
+// querier is a connection or a transaction
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// fetchRows runs query on q and scans the result into row maps
+func fetchRows(ctx context.Context, q querier, query string, opts QueryOptions) (results []map[string]interface{}, truncated bool, err error) {
+	opts.report(PhaseExecuting, 0)
+	_, execute := tracing.Tracer().Start(ctx, "db.execute")
+	stop := opts.heartbeat()
+	rows, err := q.QueryContext(ctx, query)
+	stop()
+	execute.End()
 	if err != nil {
-		return nil, fmt.Errorf("query failed: %w", err)
+		return nil, false, fmt.Errorf("query failed: %w", err)
 	}
 	defer rows.Close()
+	opts.report(PhaseFetching, 0)
+	_, fetch := tracing.Tracer().Start(ctx, "db.fetch")
+	defer fetch.End()
 
 	columns, err := rows.Columns()
 	if err != nil {
-		return nil, fmt.Errorf("failed to get columns: %w", err)
+		return nil, false, fmt.Errorf("failed to get columns: %w", err)
 	}
 
-	var results []map[string]interface{}
 	for rows.Next() {
+		if opts.MaxRows > 0 && len(results) >= opts.MaxRows {
+			truncated = true
+			break
+		}
+
 		values := make([]interface{}, len(columns))
 		valuePtrs := make([]interface{}, len(columns))
 		for i := range columns {
@@ -145,7 +336,7 @@ func (db *DB) ExecuteQuery(query string) ([]map[string]interface{}, error) {
 		}
 
 		if err := rows.Scan(valuePtrs...); err != nil {
-			return nil, fmt.Errorf("failed to scan row: %w", err)
+			return nil, false, fmt.Errorf("failed to scan row: %w", err)
 		}
 
 		row := make(map[string]interface{})
@@ -158,13 +349,92 @@ func (db *DB) ExecuteQuery(query string) ([]map[string]interface{}, error) {
 			}
 		}
 		results = append(results, row)
+		if len(results)%progressRowInterval == 0 {
+			opts.report(PhaseFetching, len(results))
+		}
 	}
 
 	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("row iteration error: %w", err)
+		return nil, false, fmt.Errorf("row iteration error: %w", err)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, false, fmt.Errorf("query cancelled: %w", err)
+	}
+
+	return results, truncated, nil
+}
+
+// acquire takes a connection for the caller in ctx from the pool its
+// identity mode selects and applies band, plus PROXYUSER in proxy mode, to
+// the session. release clears the band and returns the connection.
+func (db *DB) acquire(ctx context.Context, band map[string]string) (conn *sql.Conn, release func(), err error) {
+	pool, band, err := db.session(ctx, band)
+	if err != nil {
+		return nil, nil, err
+	}
+	conn, err = pool.Conn(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get connection: %w", err)
+	}
+	if len(band) == 0 {
+		return conn, func() { conn.Close() }, nil
+	}
+	if _, err := conn.ExecContext(ctx, "SET QUERY_BAND = '"+FormatQueryBand(band)+"' FOR SESSION"); err != nil {
+		conn.Close()
+		return nil, nil, fmt.Errorf("failed to set query band: %w", err)
+	}
+	return conn, func() {
+		clearQueryBand(conn)
+		conn.Close()
+	}, nil
+}
+
+// FormatQueryBand renders band as Teradata query band pairs, "k1=v1;k2=v2;",
+// in key order. Characters that would break the band ('=', ';' and quotes)
+// are replaced with '_'.
+func FormatQueryBand(band map[string]string) string {
+	keys := make([]string, 0, len(band))
+	for k := range band {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	clean := strings.NewReplacer("=", "_", ";", "_", "'", "_", "\"", "_")
+	var b strings.Builder
+	for _, k := range keys {
+		b.WriteString(clean.Replace(k) + "=" + clean.Replace(band[k]) + ";")
+	}
+	return b.String()
+}
+
+// clearQueryBand resets the session's query band before the connection
+// returns to the pool. If that fails the connection is discarded so the
+// band cannot leak into another caller's queries.
+func clearQueryBand(conn *sql.Conn) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err := conn.ExecContext(ctx, "SET QUERY_BAND = NONE FOR SESSION"); err != nil {
+		_ = conn.Raw(func(any) error { return driver.ErrBadConn })
+	}
+}
+
+// Name returns the connection name used in logs and audit records
+func (db *DB) Name() string {
+	if db.config == nil {
+		return ""
 	}
+	return db.config.ConnectionName()
+}
 
-	return results, nil
+// ConnectionName returns Name, defaulting to the DSN, or "default" when
+// connecting by connection string
+func (c *Config) ConnectionName() string {
+	if c.Name != "" {
+		return c.Name
+	}
+	if c.ConnectionString == "" && c.DSN != "" {
+		return c.DSN
+	}
+	return "default"
 }
 
 // DSN returns the configured DSN string
@@ -185,8 +455,47 @@ func (db *DB) Type() string {
 
 // Ping checks the database connection
 func (db *DB) Ping() error {
+	return db.PingContext(context.Background())
+}
+
+// PingContext checks the database connection, giving up when ctx is done
+func (db *DB) PingContext(ctx context.Context) error {
 	if db.conn != nil {
-		return db.conn.Ping()
+		return db.conn.PingContext(ctx)
 	}
 	return fmt.Errorf("no database connection")
 }
+
+// Explain runs EXPLAIN for query and returns the plan text, one line per row
+func (db *DB) Explain(ctx context.Context, query string) (string, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "db.explain", trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		tracing.AttrConnection.String(db.Name()),
+		tracing.AttrStatementHash.String(tracing.StatementHash(query)),
+	))
+	defer span.End()
+
+	conn, release, err := db.acquire(ctx, nil)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+	rows, err := conn.QueryContext(ctx, "EXPLAIN "+query)
+	if err != nil {
+		return "", fmt.Errorf("explain failed: %w", err)
+	}
+	defer rows.Close()
+
+	var lines []string
+	for rows.Next() {
+		var line sql.NullString
+		if err := rows.Scan(&line); err != nil {
+			return "", fmt.Errorf("failed to scan explain row: %w", err)
+		}
+		lines = append(lines, strings.TrimRight(line.String, "\r\n"))
+	}
+	if err := rows.Err(); err != nil {
+		return "", fmt.Errorf("explain row iteration error: %w", err)
+	}
+
+	return strings.Join(lines, "\n"), nil
+}
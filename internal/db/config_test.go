@@ -0,0 +1,41 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// slowQuerier blocks like a long statement and then fails
+type slowQuerier struct{ delay time.Duration }
+
+func (q slowQuerier) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	time.Sleep(q.delay)
+	return nil, errors.New("spool space exceeded")
+}
+
+func TestHeartbeatWhileExecuting(t *testing.T) {
+	defer func(saved time.Duration) { heartbeatInterval = saved }(heartbeatInterval)
+	heartbeatInterval = 10 * time.Millisecond
+
+	var beats atomic.Int32
+	var last atomic.Int64
+	opts := QueryOptions{Heartbeat: func(elapsed time.Duration) {
+		beats.Add(1)
+		last.Store(int64(elapsed))
+	}}
+	if _, _, err := fetchRows(context.Background(), slowQuerier{delay: 80 * time.Millisecond}, "SELECT 1", opts); err == nil {
+		t.Fatal("expected the query error")
+	}
+	n := beats.Load()
+	if n == 0 || time.Duration(last.Load()) < heartbeatInterval {
+		t.Fatalf("expected heartbeats with elapsed time, got %d (last %v)", n, time.Duration(last.Load()))
+	}
+	time.Sleep(3 * heartbeatInterval)
+	if beats.Load() != n {
+		t.Fatal("expected heartbeats to stop once the statement returned")
+	}
+}
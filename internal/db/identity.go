@@ -0,0 +1,206 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Identity modes select which database account runs a caller's queries
+const (
+	// IdentityShared runs every query as the service account in the DSN
+	IdentityShared = "shared"
+	// IdentityProxy connects as the service account and sets the Teradata
+	// PROXYUSER query band to the caller, so grants and DBQL see the caller
+	IdentityProxy = "proxy"
+	// IdentityCredentials gives each caller a pool of its own, opened with
+	// the username and password mapped to it in the credentials file
+	IdentityCredentials = "credentials"
+)
+
+// ErrNoIdentity means the caller has no database identity and shared
+// fallback is off
+var ErrNoIdentity = errors.New("no database identity for caller")
+
+// IdentityConfig controls pass-through of the caller's identity to the database
+type IdentityConfig struct {
+	// Mode is "shared" (default), "proxy" or "credentials"
+	Mode string `yaml:"mode"`
+	// CredentialsFile maps callers to database logins (credentials mode) or
+	// to proxy user names (proxy mode; unmapped callers proxy as themselves)
+	CredentialsFile string `yaml:"credentials_file"`
+	// AllowShared runs queries from anonymous or unmapped callers as the
+	// service account instead of rejecting them
+	AllowShared bool `yaml:"allow_shared"`
+	// MaxConnectionsPerUser caps each caller's pool in credentials mode
+	MaxConnectionsPerUser int `yaml:"max_connections_per_user"`
+}
+
+// Credential is a caller's database login in the credentials file. In proxy
+// mode only ProxyUser is used.
+type Credential struct {
+	Username  string `yaml:"username"`
+	Password  string `yaml:"password"`
+	ProxyUser string `yaml:"proxy_user"`
+}
+
+// LoadCredentials reads a credentials file of the form
+//
+//	users:
+//	  alice: {username: ALICE, password: ...}
+//	  bob:   {proxy_user: BOB_DB}
+func LoadCredentials(path string) (map[string]Credential, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read credentials file: %w", err)
+	}
+	var file struct {
+		Users map[string]Credential `yaml:"users"`
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse credentials file: %w", err)
+	}
+	return file.Users, nil
+}
+
+type userKey struct{}
+
+// WithUser returns a context whose queries run as the named caller,
+// according to the connection's identity mode
+func WithUser(ctx context.Context, user string) context.Context {
+	return context.WithValue(ctx, userKey{}, user)
+}
+
+// UserFromContext returns the caller set by WithUser, or ""
+func UserFromContext(ctx context.Context) string {
+	user, _ := ctx.Value(userKey{}).(string)
+	return user
+}
+
+// withCredentials returns the ODBC connection string for logging in as
+// cred. A login already in the configured string is removed first: ODBC
+// uses the first occurrence of a repeated keyword, so the service account's
+// would otherwise win.
+func (c *Config) withCredentials(cred Credential) string {
+	base := c.ConnectionString
+	if base == "" {
+		shared := *c
+		shared.Username, shared.Password = "", ""
+		base = shared.GetConnectionString()
+	}
+	var parts []string
+	for _, part := range splitConnectionString(base) {
+		key, _, _ := strings.Cut(part, "=")
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "uid", "pwd", "user", "user id", "username", "password":
+			continue
+		}
+		parts = append(parts, part)
+	}
+	parts = append(parts, "uid="+quoteODBC(cred.Username), "pwd="+quoteODBC(cred.Password))
+	return strings.Join(parts, ";")
+}
+
+// splitConnectionString splits an ODBC connection string into its
+// key=value attributes, keeping ; inside {braced} values
+func splitConnectionString(s string) []string {
+	var parts []string
+	var part strings.Builder
+	braced := false
+	for i := 0; i < len(s); i++ {
+		switch ch := s[i]; {
+		case braced && ch == '}' && i+1 < len(s) && s[i+1] == '}':
+			part.WriteString("}}")
+			i++
+			continue
+		case braced && ch == '}':
+			braced = false
+		case !braced && ch == '{':
+			braced = true
+		case !braced && ch == ';':
+			if strings.TrimSpace(part.String()) != "" {
+				parts = append(parts, part.String())
+			}
+			part.Reset()
+			continue
+		}
+		part.WriteByte(s[i])
+	}
+	if strings.TrimSpace(part.String()) != "" {
+		parts = append(parts, part.String())
+	}
+	return parts
+}
+
+// quoteODBC braces a connection string value so ; and } in it are literal
+func quoteODBC(v string) string {
+	return "{" + strings.ReplaceAll(v, "}", "}}") + "}"
+}
+
+// session picks the pool and query band for the caller in ctx. In proxy
+// mode band gains PROXYUSER; the returned band may be band itself.
+func (db *DB) session(ctx context.Context, band map[string]string) (*sql.DB, map[string]string, error) {
+	mode := db.config.Identity.Mode
+	if mode == "" || mode == IdentityShared {
+		return db.conn, band, nil
+	}
+	user := UserFromContext(ctx)
+	cred, mapped := db.credentials[user]
+	switch mode {
+	case IdentityProxy:
+		if user == "" {
+			break
+		}
+		proxy := cred.ProxyUser
+		if proxy == "" {
+			proxy = user
+		}
+		withProxy := make(map[string]string, len(band)+1)
+		for k, v := range band {
+			withProxy[k] = v
+		}
+		withProxy["PROXYUSER"] = proxy
+		return db.conn, withProxy, nil
+	case IdentityCredentials:
+		if !mapped || cred.Username == "" {
+			break
+		}
+		pool, err := db.userPool(user, cred)
+		return pool, band, err
+	}
+	if db.config.Identity.AllowShared {
+		return db.conn, band, nil
+	}
+	if user == "" {
+		return nil, nil, fmt.Errorf("%w: the caller is not authenticated", ErrNoIdentity)
+	}
+	return nil, nil, fmt.Errorf("%w: %s", ErrNoIdentity, user)
+}
+
+// userPool returns the caller's connection pool, opening it on first use
+func (db *DB) userPool(user string, cred Credential) (*sql.DB, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if pool, ok := db.users[user]; ok {
+		return pool, nil
+	}
+	pool, err := sql.Open(db.config.Driver, db.config.withCredentials(cred))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open connection for %s: %w", user, err)
+	}
+	maxConns := db.config.Identity.MaxConnectionsPerUser
+	if maxConns <= 0 {
+		maxConns = 2
+	}
+	pool.SetMaxOpenConns(maxConns)
+	pool.SetMaxIdleConns(maxConns)
+	pool.SetConnMaxIdleTime(5 * time.Minute)
+	db.users[user] = pool
+	return pool, nil
+}
@@ -0,0 +1,94 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWithCredentials(t *testing.T) {
+	cred := Credential{Username: "ALICE", Password: "secret"}
+	cases := []struct {
+		config Config
+		want   string
+	}{
+		{Config{DSN: "teradw"}, "dsn=teradw;uid={ALICE};pwd={secret}"},
+		{Config{ConnectionString: "driver=Teradata;dbcname=td1;"}, "driver=Teradata;dbcname=td1;uid={ALICE};pwd={secret}"},
+		{Config{Host: "td1", Username: "svc", Password: "x"}, "server=td1;uid={ALICE};pwd={secret}"},
+		// The service account's login must not come first, or ODBC uses it
+		{Config{ConnectionString: "uid=svc;PWD={a;b};driver=Teradata"}, "driver=Teradata;uid={ALICE};pwd={secret}"},
+	}
+	for _, tc := range cases {
+		if got := tc.config.withCredentials(cred); got != tc.want {
+			t.Fatalf("withCredentials(%+v) = %q, want %q", tc.config, got, tc.want)
+		}
+	}
+
+	dsn := Config{DSN: "teradw"}
+	quoted := dsn.withCredentials(Credential{Username: "BOB", Password: "p;w}d"})
+	if quoted != "dsn=teradw;uid={BOB};pwd={p;w}}d}" {
+		t.Fatalf("expected the password to be brace-quoted, got %q", quoted)
+	}
+}
+
+func TestCredentialsModeRequiresODBC(t *testing.T) {
+	_, err := Connect(&Config{Driver: "postgres", Identity: IdentityConfig{Mode: IdentityCredentials, CredentialsFile: "unused.yaml"}})
+	if err == nil || !strings.Contains(err.Error(), "requires the odbc driver") {
+		t.Fatalf("expected credentials mode to be rejected for a non-odbc driver, got %v", err)
+	}
+}
+
+func TestSessionProxy(t *testing.T) {
+	db := &DB{
+		config:      &Config{Identity: IdentityConfig{Mode: IdentityProxy}},
+		credentials: map[string]Credential{"alice": {ProxyUser: "ALICE_DB"}},
+	}
+	band := map[string]string{"Tool": "count_records"}
+
+	_, got, err := db.session(WithUser(context.Background(), "alice"), band)
+	if err != nil || got["PROXYUSER"] != "ALICE_DB" || got["Tool"] != "count_records" {
+		t.Fatalf("expected mapped proxy user, got %v, %v", got, err)
+	}
+	if _, ok := band["PROXYUSER"]; ok {
+		t.Fatal("session must not modify the caller's band")
+	}
+	if _, got, _ := db.session(WithUser(context.Background(), "bob"), band); got["PROXYUSER"] != "bob" {
+		t.Fatalf("expected unmapped caller to proxy as itself, got %v", got)
+	}
+	if _, _, err := db.session(context.Background(), band); !errors.Is(err, ErrNoIdentity) {
+		t.Fatalf("expected ErrNoIdentity for anonymous caller, got %v", err)
+	}
+
+	db.config.Identity.AllowShared = true
+	if _, got, err := db.session(context.Background(), band); err != nil || got["PROXYUSER"] != "" {
+		t.Fatalf("expected shared fallback, got %v, %v", got, err)
+	}
+}
+
+func TestSessionCredentialsUnmapped(t *testing.T) {
+	db := &DB{
+		config:      &Config{Identity: IdentityConfig{Mode: IdentityCredentials}},
+		credentials: map[string]Credential{"alice": {Username: "ALICE", Password: "secret"}},
+	}
+	if _, _, err := db.session(WithUser(context.Background(), "bob"), nil); !errors.Is(err, ErrNoIdentity) {
+		t.Fatalf("expected ErrNoIdentity for unmapped caller, got %v", err)
+	}
+}
+
+func TestLoadCredentials(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "credentials.yaml")
+	data := "users:\n  alice: {username: ALICE, password: secret}\n  bob: {proxy_user: BOB_DB}\n"
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatalf("write credentials: %v", err)
+	}
+	creds, err := LoadCredentials(path)
+	if err != nil {
+		t.Fatalf("LoadCredentials: %v", err)
+	}
+	if creds["alice"].Username != "ALICE" || creds["bob"].ProxyUser != "BOB_DB" {
+		t.Fatalf("unexpected credentials: %+v", creds)
+	}
+}
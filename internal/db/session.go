@@ -0,0 +1,55 @@
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"td_go_mcp/internal/tracing"
+)
+
+// Session runs several statements on one connection, so volatile tables
+// and session settings carry from one statement to the next
+type Session struct {
+	db   *DB
+	q    querier
+	opts QueryOptions
+}
+
+// WithSession holds one connection for the caller in ctx while fn runs.
+// With transaction set the statements run in one transaction, committed
+// when fn returns nil and rolled back otherwise.
+func (db *DB) WithSession(ctx context.Context, opts QueryOptions, transaction bool, fn func(*Session) error) error {
+	opts.report(PhaseConnecting, 0)
+	_, acquire := tracing.Tracer().Start(ctx, "db.acquire_connection")
+	conn, release, err := db.acquire(ctx, opts.QueryBand)
+	acquire.End()
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	if !transaction {
+		return fn(&Session{db: db, q: conn, opts: opts})
+	}
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	if err := fn(&Session{db: db, q: tx, opts: opts}); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			return fmt.Errorf("%w (rollback failed: %v)", err, rbErr)
+		}
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// Query runs one statement in the session, like ExecuteQueryContext
+func (s *Session) Query(ctx context.Context, query string) (results []map[string]interface{}, truncated bool, err error) {
+	ctx, done := s.db.startQuery(ctx, query)
+	defer func() { done(len(results), err) }()
+	return fetchRows(ctx, s.q, query, s.opts)
+}
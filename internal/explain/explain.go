@@ -0,0 +1,187 @@
+package explain
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Plan is a parsed Teradata EXPLAIN output
+type Plan struct {
+	Raw                string  `json:"raw"`
+	Steps              []Step  `json:"steps"`
+	TotalEstimatedTime float64 `json:"total_estimated_time_seconds,omitempty"`
+}
+
+// Step is one numbered step of an EXPLAIN plan. Steps executed in parallel
+// are numbered "<parent>.<n>".
+type Step struct {
+	Number           string   `json:"number"`
+	Operation        string   `json:"operation"`
+	AMPs             string   `json:"amps,omitempty"`
+	Spool            string   `json:"spool,omitempty"`
+	EstimatedRows    int64    `json:"estimated_rows,omitempty"`
+	EstimatedBytes   int64    `json:"estimated_bytes,omitempty"`
+	EstimatedSeconds float64  `json:"estimated_seconds,omitempty"`
+	Confidence       string   `json:"confidence,omitempty"`
+	JoinTypes        []string `json:"join_types,omitempty"`
+	Text             string   `json:"text"`
+}
+
+var (
+	stepStartRe  = regexp.MustCompile(`^\s*(\d+)\)\s+(.*)$`)
+	resultLineRe = regexp.MustCompile(`^\s*->\s*(.*)$`)
+	operationRe  = regexp.MustCompile(`(?i)\bwe do an?\s+(?:(all-AMPs|single-AMP|group-AMPs|two-AMP|few-AMPs)\s+)?([A-Z][A-Z ]*?)\s+step\b`)
+	sendOutRe    = regexp.MustCompile(`(?i)\bwe send out an?\s+([A-Z][A-Z ]*?)\s+step\b`)
+	spoolRe      = regexp.MustCompile(`(?i)\binto Spool (\d+)`)
+	estimateRe   = regexp.MustCompile(`(?i)estimated\s+(?:with\s+(no|low|high|index join)\s+confidence\s+)?to be\s+([\d,]+)\s+rows?(?:\s*\(\s*([\d,]+)\s+bytes\s*\))?`)
+	stepTimeRe   = regexp.MustCompile(`(?i)estimated time for this step is\s+([^.]+(?:\.\d+)?(?:\s*seconds?)?)`)
+	totalTimeRe  = regexp.MustCompile(`(?i)total estimated time is\s+([^.]+(?:\.\d+)?(?:\s*seconds?)?)`)
+	joinRe       = regexp.MustCompile(`(?i)\b((?:[a-z-]+\s+){0,3}?)(merge|product|hash|nested|exclusion|inclusion|row id)\s+join\b`)
+	hmsRe        = regexp.MustCompile(`^(\d+):(\d+):(\d+(?:\.\d+)?)$`)
+	unitRe       = regexp.MustCompile(`(?i)([\d.]+)\s*(hours?|minutes?|seconds?)`)
+)
+
+// joinQualifiers are words kept in front of a join type, e.g. "exclusion merge join"
+var joinQualifiers = map[string]bool{
+	"exclusion": true, "inclusion": true, "single": true, "partition": true,
+	"dynamic": true, "rowkey-based": true, "sliding-window": true, "classical": true,
+}
+
+// Parse converts the text of a Teradata EXPLAIN into a Plan. Unrecognised
+// lines are kept in the raw text but do not produce steps.
+func Parse(raw string) Plan {
+	plan := Plan{Raw: raw, Steps: []Step{}}
+
+	type block struct {
+		number string
+		lines  []string
+	}
+	var blocks []block
+	parent := ""
+	parallelIndent := -1
+
+	for _, line := range strings.Split(raw, "\n") {
+		if m := resultLineRe.FindStringSubmatch(line); m != nil {
+			blocks = append(blocks, block{lines: []string{m[1]}})
+			parallelIndent = -1
+			continue
+		}
+		if m := stepStartRe.FindStringSubmatch(line); m != nil {
+			indent := len(line) - len(strings.TrimLeft(line, " \t"))
+			number := m[1]
+			if parallelIndent >= 0 && indent > parallelIndent {
+				// Sub-step of a "steps in parallel" group
+				number = parent + "." + m[1]
+			} else {
+				parent = m[1]
+				parallelIndent = -1
+				if strings.Contains(strings.ToLower(m[2]), "in parallel") {
+					parallelIndent = indent
+				}
+			}
+			blocks = append(blocks, block{number: number, lines: []string{m[2]}})
+			continue
+		}
+		if len(blocks) > 0 {
+			blocks[len(blocks)-1].lines = append(blocks[len(blocks)-1].lines, strings.TrimSpace(line))
+		}
+	}
+
+	for _, b := range blocks {
+		text := strings.Join(strings.Fields(strings.Join(b.lines, " ")), " ")
+		if b.number == "" {
+			if m := totalTimeRe.FindStringSubmatch(text); m != nil {
+				plan.TotalEstimatedTime = parseDuration(m[1])
+			}
+			continue
+		}
+		plan.Steps = append(plan.Steps, parseStep(b.number, text))
+	}
+	return plan
+}
+
+func parseStep(number, text string) Step {
+	step := Step{Number: number, Text: text}
+	lower := strings.ToLower(text)
+
+	switch {
+	case operationRe.MatchString(text):
+		m := operationRe.FindStringSubmatch(text)
+		step.AMPs = m[1]
+		step.Operation = strings.ToUpper(strings.TrimSpace(m[2]))
+	case sendOutRe.MatchString(text):
+		step.Operation = strings.ToUpper(strings.TrimSpace(sendOutRe.FindStringSubmatch(text)[1]))
+	case strings.Contains(lower, "in parallel"):
+		step.Operation = "PARALLEL"
+	case strings.Contains(lower, "we lock"), strings.HasPrefix(lower, "lock"):
+		step.Operation = "LOCK"
+	default:
+		step.Operation = "OTHER"
+	}
+
+	if m := spoolRe.FindStringSubmatch(text); m != nil {
+		step.Spool = "Spool " + m[1]
+	}
+	if m := estimateRe.FindStringSubmatch(text); m != nil {
+		step.Confidence = strings.ToLower(m[1])
+		step.EstimatedRows = parseCount(m[2])
+		step.EstimatedBytes = parseCount(m[3])
+	}
+	if m := stepTimeRe.FindStringSubmatch(text); m != nil {
+		step.EstimatedSeconds = parseDuration(m[1])
+	}
+	for _, m := range joinRe.FindAllStringSubmatch(text, -1) {
+		var qualifiers []string
+		for _, w := range strings.Fields(strings.ToLower(m[1])) {
+			if joinQualifiers[w] {
+				qualifiers = append(qualifiers, w)
+			} else {
+				qualifiers = nil
+			}
+		}
+		joinType := strings.Join(append(qualifiers, strings.ToLower(m[2])), " ")
+		if !containsString(step.JoinTypes, joinType) {
+			step.JoinTypes = append(step.JoinTypes, joinType)
+		}
+	}
+	return step
+}
+
+// parseDuration understands "0.18 seconds", "1 minute and 14 seconds" and "00:01:14.50"
+func parseDuration(s string) float64 {
+	s = strings.TrimSpace(s)
+	if m := hmsRe.FindStringSubmatch(s); m != nil {
+		h, _ := strconv.ParseFloat(m[1], 64)
+		min, _ := strconv.ParseFloat(m[2], 64)
+		sec, _ := strconv.ParseFloat(m[3], 64)
+		return h*3600 + min*60 + sec
+	}
+	total := 0.0
+	for _, m := range unitRe.FindAllStringSubmatch(s, -1) {
+		v, _ := strconv.ParseFloat(m[1], 64)
+		switch strings.ToLower(m[2])[0] {
+		case 'h':
+			total += v * 3600
+		case 'm':
+			total += v * 60
+		default:
+			total += v
+		}
+	}
+	return total
+}
+
+func parseCount(s string) int64 {
+	n, _ := strconv.ParseInt(strings.ReplaceAll(s, ",", ""), 10, 64)
+	return n
+}
+
+func containsString(slice []string, item string) bool {
+	for _, s := range slice {
+		if s == item {
+			return true
+		}
+	}
+	return false
+}
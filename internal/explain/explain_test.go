@@ -0,0 +1,86 @@
+package explain
+
+import (
+	"testing"
+)
+
+const sampleExplain = `  1) First, we lock a distinct SALES."pseudo table" for read on a
+     RowHash to prevent global deadlock for SALES.orders.
+  2) Next, we lock SALES.orders for read, and we lock SALES.customers
+     for read.
+  3) We execute the following steps in parallel.
+       1) We do an all-AMPs RETRIEVE step from SALES.orders by way of
+          an all-rows scan with no residual conditions into Spool 2
+          (all_amps), which is redistributed by the hash code of (
+          SALES.orders.customer_id) to all AMPs.  The size of Spool 2
+          is estimated with high confidence to be 1,250,000 rows (
+          37,500,000 bytes).  The estimated time for this step is 1.52
+          seconds.
+       2) We do an all-AMPs RETRIEVE step from SALES.customers by way
+          of an all-rows scan with no residual conditions into Spool 3
+          (all_amps), which is duplicated on all AMPs.  The size of
+          Spool 3 is estimated with low confidence to be 40,000 rows (
+          960,000 bytes).  The estimated time for this step is 0.08
+          seconds.
+  4) We do an all-AMPs JOIN step from Spool 2 (Last Use) by way of an
+     all-rows scan, which is joined to Spool 3 (Last Use) by way of an
+     all-rows scan.  Spool 2 and Spool 3 are joined using a product
+     join, with a join condition of ("(1=1)").  The result goes into
+     Spool 1 (group_amps), which is built locally on the AMPs.  The
+     size of Spool 1 is estimated with no confidence to be 50,000,000
+     rows (2,000,000,000 bytes).  The estimated time for this step is
+     1 minute and 14 seconds.
+  5) Finally, we send out an END TRANSACTION step to all AMPs involved
+     in processing the request.
+  -> The contents of Spool 1 are sent back to the user as the result of
+     statement 1.  The total estimated time is 1 minute and 16 seconds.`
+
+func TestParse(t *testing.T) {
+	plan := Parse(sampleExplain)
+
+	wantNumbers := []string{"1", "2", "3", "3.1", "3.2", "4", "5"}
+	if len(plan.Steps) != len(wantNumbers) {
+		t.Fatalf("expected %d steps, got %d: %+v", len(wantNumbers), len(plan.Steps), plan.Steps)
+	}
+	for i, n := range wantNumbers {
+		if plan.Steps[i].Number != n {
+			t.Errorf("step %d: expected number %s, got %s", i, n, plan.Steps[i].Number)
+		}
+	}
+
+	if op := plan.Steps[0].Operation; op != "LOCK" {
+		t.Errorf("expected LOCK operation, got %s", op)
+	}
+	if op := plan.Steps[2].Operation; op != "PARALLEL" {
+		t.Errorf("expected PARALLEL operation, got %s", op)
+	}
+
+	retrieve := plan.Steps[3]
+	if retrieve.Operation != "RETRIEVE" || retrieve.AMPs != "all-AMPs" {
+		t.Errorf("unexpected retrieve step: %+v", retrieve)
+	}
+	if retrieve.Spool != "Spool 2" || retrieve.EstimatedRows != 1250000 || retrieve.EstimatedBytes != 37500000 {
+		t.Errorf("unexpected retrieve estimates: %+v", retrieve)
+	}
+	if retrieve.Confidence != "high" || retrieve.EstimatedSeconds != 1.52 {
+		t.Errorf("unexpected retrieve confidence/time: %+v", retrieve)
+	}
+
+	join := plan.Steps[5]
+	if join.Operation != "JOIN" || join.Spool != "Spool 1" || join.Confidence != "no" {
+		t.Errorf("unexpected join step: %+v", join)
+	}
+	if len(join.JoinTypes) != 1 || join.JoinTypes[0] != "product" {
+		t.Errorf("expected product join, got %v", join.JoinTypes)
+	}
+	if join.EstimatedSeconds != 74 {
+		t.Errorf("expected 74 seconds, got %v", join.EstimatedSeconds)
+	}
+
+	if op := plan.Steps[6].Operation; op != "END TRANSACTION" {
+		t.Errorf("expected END TRANSACTION, got %s", op)
+	}
+	if plan.TotalEstimatedTime != 76 {
+		t.Errorf("expected total 76 seconds, got %v", plan.TotalEstimatedTime)
+	}
+}
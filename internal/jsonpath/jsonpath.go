@@ -0,0 +1,251 @@
+// Package jsonpath evaluates the subset of JSONPath used to wire pipeline
+// steps together: $.a.b, $['a'], $.list[0], $.list[-1] and the wildcards
+// $.list[*] and $.obj.*.
+package jsonpath
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Expr is a parsed path
+type Expr struct {
+	src      string
+	segments []segment
+}
+
+type segment struct {
+	field    string
+	index    int
+	isIndex  bool
+	wildcard bool
+}
+
+// IsExpr reports whether v is a string that should be evaluated as a path
+func IsExpr(v any) bool {
+	s, ok := v.(string)
+	return ok && strings.HasPrefix(s, "$")
+}
+
+// Parse parses a path starting at the root, "$"
+func Parse(src string) (*Expr, error) {
+	if !strings.HasPrefix(src, "$") {
+		return nil, fmt.Errorf("path %q must start with $", src)
+	}
+	e := &Expr{src: src}
+	rest := src[1:]
+	for rest != "" {
+		switch {
+		case strings.HasPrefix(rest, "."):
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			name := rest[:end]
+			if name == "" {
+				return nil, fmt.Errorf("path %q has an empty field name", src)
+			}
+			if name == "*" {
+				e.segments = append(e.segments, segment{wildcard: true})
+			} else {
+				e.segments = append(e.segments, segment{field: name})
+			}
+			rest = rest[end:]
+		case strings.HasPrefix(rest, "["):
+			end := strings.Index(rest, "]")
+			if end < 0 {
+				return nil, fmt.Errorf("path %q has an unclosed [", src)
+			}
+			inner := strings.TrimSpace(rest[1:end])
+			rest = rest[end+1:]
+			switch {
+			case inner == "*":
+				e.segments = append(e.segments, segment{wildcard: true})
+			case len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0]:
+				e.segments = append(e.segments, segment{field: inner[1 : len(inner)-1]})
+			default:
+				n, err := strconv.Atoi(inner)
+				if err != nil {
+					return nil, fmt.Errorf("path %q has an invalid index [%s]", src, inner)
+				}
+				e.segments = append(e.segments, segment{index: n, isIndex: true})
+			}
+		default:
+			return nil, fmt.Errorf("path %q: unexpected %q", src, rest)
+		}
+	}
+	return e, nil
+}
+
+// String returns the path as written
+func (e *Expr) String() string {
+	return e.src
+}
+
+// Eval applies the path to doc, which holds decoded JSON (maps, slices and
+// scalars). A path with a wildcard returns a []any of every match and skips
+// elements that lack the rest of the path; otherwise a missing field or
+// index is an error.
+func (e *Expr) Eval(doc any) (any, error) {
+	nodes := []any{doc}
+	multi := false
+	for _, seg := range e.segments {
+		var next []any
+		for _, node := range nodes {
+			matches, err := seg.apply(node)
+			if err != nil {
+				if multi {
+					continue
+				}
+				return nil, fmt.Errorf("%s: %w", e.src, err)
+			}
+			next = append(next, matches...)
+		}
+		if seg.wildcard {
+			multi = true
+		}
+		nodes = next
+	}
+	if multi {
+		if nodes == nil {
+			nodes = []any{}
+		}
+		return nodes, nil
+	}
+	return nodes[0], nil
+}
+
+func (s segment) apply(node any) ([]any, error) {
+	switch {
+	case s.wildcard:
+		switch v := node.(type) {
+		case []any:
+			return v, nil
+		case map[string]any:
+			keys := make([]string, 0, len(v))
+			for k := range v {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			out := make([]any, 0, len(v))
+			for _, k := range keys {
+				out = append(out, v[k])
+			}
+			return out, nil
+		}
+		return nil, fmt.Errorf("cannot use * on %T", node)
+	case s.isIndex:
+		list, ok := node.([]any)
+		if !ok {
+			return nil, fmt.Errorf("cannot index %T", node)
+		}
+		i := s.index
+		if i < 0 {
+			i += len(list)
+		}
+		if i < 0 || i >= len(list) {
+			return nil, fmt.Errorf("index %d out of range (length %d)", s.index, len(list))
+		}
+		return []any{list[i]}, nil
+	default:
+		obj, ok := node.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("cannot read field %q of %T", s.field, node)
+		}
+		v, ok := obj[s.field]
+		if !ok {
+			return nil, fmt.Errorf("no field %q", s.field)
+		}
+		return []any{v}, nil
+	}
+}
+
+// Resolve copies tmpl, a decoded YAML or JSON value, replacing every string
+// that is a path with its value in doc. Other values are kept as literals.
+func Resolve(tmpl any, doc any) (any, error) {
+	switch v := tmpl.(type) {
+	case string:
+		if !IsExpr(v) {
+			return v, nil
+		}
+		expr, err := Parse(v)
+		if err != nil {
+			return nil, err
+		}
+		return expr.Eval(doc)
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for k, item := range v {
+			resolved, err := Resolve(item, doc)
+			if err != nil {
+				return nil, err
+			}
+			out[k] = resolved
+		}
+		return out, nil
+	case []any:
+		out := make([]any, 0, len(v))
+		for _, item := range v {
+			resolved, err := Resolve(item, doc)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, resolved)
+		}
+		return out, nil
+	}
+	return tmpl, nil
+}
+
+// Paths returns every path in tmpl, parsed, or the first that does not parse
+func Paths(tmpl any) ([]*Expr, error) {
+	var exprs []*Expr
+	switch v := tmpl.(type) {
+	case string:
+		if IsExpr(v) {
+			expr, err := Parse(v)
+			if err != nil {
+				return nil, err
+			}
+			exprs = append(exprs, expr)
+		}
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			more, err := Paths(v[k])
+			if err != nil {
+				return nil, err
+			}
+			exprs = append(exprs, more...)
+		}
+	case []any:
+		for _, item := range v {
+			more, err := Paths(item)
+			if err != nil {
+				return nil, err
+			}
+			exprs = append(exprs, more...)
+		}
+	}
+	return exprs, nil
+}
+
+// Root returns the first n field names of the path, stopping early at an
+// index or wildcard
+func (e *Expr) Root(n int) []string {
+	var fields []string
+	for _, seg := range e.segments {
+		if len(fields) == n || seg.isIndex || seg.wildcard {
+			break
+		}
+		fields = append(fields, seg.field)
+	}
+	return fields
+}
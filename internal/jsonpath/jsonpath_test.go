@@ -0,0 +1,91 @@
+package jsonpath
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestEval(t *testing.T) {
+	var doc any
+	if err := json.Unmarshal([]byte(`{
+		"params": {"email": "a@example.com"},
+		"steps": {
+			"user": {"rows": [{"user_id": "u1", "name": "Ann"}], "count": 1},
+			"sessions": {"rows": [{"id": "s1"}, {"id": "s2"}, {"other": 1}]}
+		}
+	}`), &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		path string
+		want any
+	}{
+		{"$.params.email", "a@example.com"},
+		{"$.steps.user.rows[0].user_id", "u1"},
+		{"$['steps']['user'].count", 1.0},
+		{"$.steps.sessions.rows[-1].other", 1.0},
+		{"$.steps.sessions.rows[*].id", []any{"s1", "s2"}},
+		{"$.steps.user.rows[0].*", []any{"Ann", "u1"}},
+		{"$.steps.sessions.rows[*].missing", []any{}},
+	} {
+		expr, err := Parse(tc.path)
+		if err != nil {
+			t.Fatalf("Parse(%s): %v", tc.path, err)
+		}
+		got, err := expr.Eval(doc)
+		if err != nil {
+			t.Fatalf("Eval(%s): %v", tc.path, err)
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: expected %#v, got %#v", tc.path, tc.want, got)
+		}
+	}
+
+	for _, path := range []string{"$.steps.user.rows[1]", "$.params.phone", "$.params.email.x"} {
+		expr, _ := Parse(path)
+		if _, err := expr.Eval(doc); err == nil {
+			t.Errorf("%s: expected an error", path)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, path := range []string{"params.email", "$.", "$.a[", "$.a[x]", "$a"} {
+		if _, err := Parse(path); err == nil {
+			t.Errorf("%s: expected a parse error", path)
+		}
+	}
+	if !IsExpr("$.a") || IsExpr("plain") || IsExpr(3) {
+		t.Error("IsExpr misclassified a value")
+	}
+}
+
+func TestResolve(t *testing.T) {
+	doc := map[string]any{"params": map[string]any{"id": "u1"}, "steps": map[string]any{"n": []any{1.0, 2.0}}}
+	tmpl := map[string]any{
+		"user":   "$.params.id",
+		"counts": []any{"$.steps.n[0]", "literal", 3},
+		"all":    "$.steps.n[*]",
+	}
+	got, err := Resolve(tmpl, doc)
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	want := map[string]any{"user": "u1", "counts": []any{1.0, "literal", 3}, "all": []any{1.0, 2.0}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %#v, got %#v", want, got)
+	}
+
+	exprs, err := Paths(tmpl)
+	if err != nil || len(exprs) != 3 {
+		t.Fatalf("expected 3 paths, got %v %v", exprs, err)
+	}
+	if root := exprs[0].Root(2); !reflect.DeepEqual(root, []string{"steps", "n"}) {
+		t.Errorf("expected root steps.n, got %v", root)
+	}
+	if _, err := Paths(map[string]any{"bad": "$.a["}); err == nil {
+		t.Error("expected Paths to report a bad path")
+	}
+}
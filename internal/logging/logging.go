@@ -0,0 +1,190 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"golang.org/x/exp/slog"
+
+	"td_go_mcp/internal/rotate"
+)
+
+// Config controls the debug log. Destination is a file path, "stderr" or
+// "off". PackageLevels overrides Level for packages whose import path ends
+// with the given key, e.g. "internal/db": "debug".
+type Config struct {
+	Level          string            `yaml:"level"`
+	Format         string            `yaml:"format"`
+	Destination    string            `yaml:"destination"`
+	AddSource      bool              `yaml:"add_source"`
+	MaxSizeMB      int               `yaml:"max_size_mb"`
+	MaxAgeDays     int               `yaml:"max_age_days"`
+	MaxBackups     int               `yaml:"max_backups"`
+	RotateInterval time.Duration     `yaml:"rotate_interval"`
+	PackageLevels  map[string]string `yaml:"package_levels"`
+}
+
+// New builds a logger for cfg. The returned io.Closer releases the log file
+// and is never nil.
+func New(cfg Config) (*slog.Logger, io.Closer, error) {
+	level, err := ParseLevel(cfg.Level)
+	if err != nil {
+		return nil, nil, err
+	}
+	overrides := make(map[string]slog.Level, len(cfg.PackageLevels))
+	for pkg, name := range cfg.PackageLevels {
+		l, err := ParseLevel(name)
+		if err != nil {
+			return nil, nil, fmt.Errorf("package %s: %w", pkg, err)
+		}
+		overrides[strings.Trim(pkg, "/")] = l
+	}
+
+	var w io.Writer
+	var closer io.Closer = nopCloser{}
+	switch strings.ToLower(cfg.Destination) {
+	case "off", "none":
+		return slog.New(discardHandler{}), closer, nil
+	case "", "stderr":
+		w = os.Stderr
+	default:
+		f, err := rotate.Open(cfg.Destination, rotate.Options{
+			MaxSizeMB:  cfg.MaxSizeMB,
+			MaxAgeDays: cfg.MaxAgeDays,
+			MaxBackups: cfg.MaxBackups,
+			Interval:   cfg.RotateInterval,
+		})
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to open log file: %w", err)
+		}
+		w, closer = f, f
+	}
+
+	// The inner handler sees everything; levelHandler does the filtering
+	opts := &slog.HandlerOptions{AddSource: cfg.AddSource, Level: minLevel(level, overrides)}
+	var handler slog.Handler
+	switch strings.ToLower(cfg.Format) {
+	case "", "text":
+		handler = slog.NewTextHandler(w, opts)
+	case "json":
+		handler = slog.NewJSONHandler(w, opts)
+	default:
+		closer.Close()
+		return nil, nil, fmt.Errorf("unknown log format %q (use text or json)", cfg.Format)
+	}
+	if len(overrides) > 0 {
+		handler = &levelHandler{next: handler, level: level, overrides: overrides}
+	}
+	return slog.New(handler), closer, nil
+}
+
+// ParseLevel accepts debug, info, warn/warning and error (case-insensitive).
+// An empty string means info.
+func ParseLevel(name string) (slog.Level, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	}
+	return slog.LevelInfo, fmt.Errorf("unknown log level %q", name)
+}
+
+func minLevel(level slog.Level, overrides map[string]slog.Level) slog.Level {
+	for _, l := range overrides {
+		if l < level {
+			level = l
+		}
+	}
+	return level
+}
+
+// levelHandler applies per-package minimum levels based on the caller's PC
+type levelHandler struct {
+	next      slog.Handler
+	level     slog.Level
+	overrides map[string]slog.Level
+}
+
+func (h *levelHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *levelHandler) Handle(ctx context.Context, r slog.Record) error {
+	if r.Level < h.levelFor(packageOf(r.PC)) {
+		return nil
+	}
+	return h.next.Handle(ctx, r)
+}
+
+func (h *levelHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &levelHandler{next: h.next.WithAttrs(attrs), level: h.level, overrides: h.overrides}
+}
+
+func (h *levelHandler) WithGroup(name string) slog.Handler {
+	return &levelHandler{next: h.next.WithGroup(name), level: h.level, overrides: h.overrides}
+}
+
+// levelFor returns the level of the longest override matching pkg
+func (h *levelHandler) levelFor(pkg string) slog.Level {
+	level := h.level
+	best := -1
+	for key, l := range h.overrides {
+		if (pkg == key || strings.HasSuffix(pkg, "/"+key)) && len(key) > best {
+			level, best = l, len(key)
+		}
+	}
+	return level
+}
+
+// mainPath is the import path of the main package, e.g. "td_go_mcp/cmd/mcp".
+// Functions there are named main.X, so overrides such as cmd/mcp=warn
+// match against this instead.
+var mainPath = func() string {
+	if info, ok := debug.ReadBuildInfo(); ok && info.Path != "" {
+		return info.Path
+	}
+	return "main"
+}()
+
+// packageOf returns the import path of the function at pc, e.g.
+// "td_go_mcp/internal/db" for "td_go_mcp/internal/db.(*DB).ExecuteQuery"
+func packageOf(pc uintptr) string {
+	if pc == 0 {
+		return ""
+	}
+	frame, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+	return packageOfFunc(frame.Function)
+}
+
+func packageOfFunc(name string) string {
+	slash := strings.LastIndex(name, "/")
+	if dot := strings.Index(name[slash+1:], "."); dot >= 0 {
+		name = name[:slash+1+dot]
+	}
+	if name == "main" {
+		return mainPath
+	}
+	return name
+}
+
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (h discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return h }
+func (h discardHandler) WithGroup(string) slog.Handler           { return h }
+
+type nopCloser struct{}
+
+func (nopCloser) Close() error { return nil }
@@ -0,0 +1,79 @@
+package logging
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/exp/slog"
+)
+
+func TestNewAppliesFormatAndPackageLevels(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mcp.log")
+	logger, closer, err := New(Config{
+		Level:         "debug",
+		Format:        "json",
+		Destination:   path,
+		PackageLevels: map[string]string{"internal/logging": "warn"},
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	logger.Info("suppressed by package override")
+	logger.Warn("kept", "n", 1)
+	if err := closer.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("expected 1 line, got %d: %s", len(lines), data)
+	}
+	var rec map[string]any
+	if err := json.Unmarshal([]byte(lines[0]), &rec); err != nil {
+		t.Fatalf("expected JSON output: %v", err)
+	}
+	if rec["msg"] != "kept" || rec["level"] != "WARN" {
+		t.Fatalf("unexpected record: %v", rec)
+	}
+}
+
+func TestNewRejectsBadConfig(t *testing.T) {
+	if _, _, err := New(Config{Level: "verbose"}); err == nil {
+		t.Error("expected error for unknown level")
+	}
+	if _, _, err := New(Config{Format: "xml"}); err == nil {
+		t.Error("expected error for unknown format")
+	}
+	logger, _, err := New(Config{Destination: "off"})
+	if err != nil || logger.Enabled(context.Background(), 100) {
+		t.Errorf("expected disabled logger for off destination, got %v", err)
+	}
+}
+
+func TestPackageOfMain(t *testing.T) {
+	defer func(saved string) { mainPath = saved }(mainPath)
+	mainPath = "td_go_mcp/cmd/mcp"
+
+	for fn, want := range map[string]string{
+		"main.main":                                "td_go_mcp/cmd/mcp",
+		"main.(*progressReporter).Phase":           "td_go_mcp/cmd/mcp",
+		"td_go_mcp/internal/db.(*DB).ExecuteQuery": "td_go_mcp/internal/db",
+	} {
+		if got := packageOfFunc(fn); got != want {
+			t.Errorf("packageOfFunc(%q) = %q, want %q", fn, got, want)
+		}
+	}
+	h := &levelHandler{level: -4, overrides: map[string]slog.Level{"cmd/mcp": 4}}
+	if h.levelFor(packageOfFunc("main.run")) != 4 {
+		t.Errorf("expected cmd/mcp=warn to apply to the main package")
+	}
+}
@@ -0,0 +1,134 @@
+package logging
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"golang.org/x/exp/slog"
+)
+
+// MCPBridge forwards slog records to MCP clients as notifications/message.
+// Only records logged with a context that carries an MCP session are
+// forwarded, and only to that session: records from REST calls, the admin
+// server or without a context may name other callers' tools and errors.
+// Each session only receives records at or above the level it chose with
+// logging/setLevel.
+type MCPBridge struct {
+	srv atomic.Pointer[server.MCPServer]
+}
+
+// NewMCPBridge creates a bridge; call Attach once the MCP server exists
+func NewMCPBridge() *MCPBridge {
+	return &MCPBridge{}
+}
+
+// Attach sets the server used to send notifications
+func (b *MCPBridge) Attach(srv *server.MCPServer) {
+	b.srv.Store(srv)
+}
+
+// Handler wraps next so records are also forwarded through the bridge
+func (b *MCPBridge) Handler(next slog.Handler) slog.Handler {
+	return &mcpHandler{next: next, bridge: b}
+}
+
+// wants reports whether the session in ctx, if any, asked for records at level
+func (b *MCPBridge) wants(ctx context.Context, level slog.Level) bool {
+	if b.srv.Load() == nil {
+		return false
+	}
+	session, ok := server.ClientSessionFromContext(ctx).(server.SessionWithLogging)
+	return ok && MCPLevel(level).ShouldSendTo(session.GetLogLevel())
+}
+
+type mcpHandler struct {
+	next   slog.Handler
+	bridge *MCPBridge
+	attrs  []slog.Attr
+	group  string
+}
+
+func (h *mcpHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	// The calling session may ask for more detail than the local log keeps
+	return h.next.Enabled(ctx, level) || h.bridge.wants(ctx, level)
+}
+
+func (h *mcpHandler) Handle(ctx context.Context, r slog.Record) error {
+	var err error
+	if h.next.Enabled(ctx, r.Level) {
+		err = h.next.Handle(ctx, r)
+	}
+	if !h.bridge.wants(ctx, r.Level) {
+		return err
+	}
+
+	data := map[string]any{"message": r.Message}
+	for _, a := range h.attrs {
+		addAttr(data, "", a)
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		addAttr(data, h.group, a)
+		return true
+	})
+	n := mcp.NewLoggingMessageNotification(MCPLevel(r.Level), strings.TrimPrefix(packageOf(r.PC), "td_go_mcp/"), data)
+	_ = h.bridge.srv.Load().SendLogMessageToClient(ctx, n)
+	return err
+}
+
+func (h *mcpHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	prefixed := make([]slog.Attr, 0, len(h.attrs)+len(attrs))
+	prefixed = append(prefixed, h.attrs...)
+	for _, a := range attrs {
+		if h.group != "" {
+			a.Key = h.group + "." + a.Key
+		}
+		prefixed = append(prefixed, a)
+	}
+	return &mcpHandler{next: h.next.WithAttrs(attrs), bridge: h.bridge, attrs: prefixed, group: h.group}
+}
+
+func (h *mcpHandler) WithGroup(name string) slog.Handler {
+	group := name
+	if h.group != "" {
+		group = h.group + "." + name
+	}
+	return &mcpHandler{next: h.next.WithGroup(name), bridge: h.bridge, attrs: h.attrs, group: group}
+}
+
+func addAttr(data map[string]any, group string, a slog.Attr) {
+	key := a.Key
+	if group != "" {
+		key = group + "." + key
+	}
+	v := a.Value.Resolve()
+	if v.Kind() == slog.KindGroup {
+		for _, ga := range v.Group() {
+			addAttr(data, key, ga)
+		}
+		return
+	}
+	if err, ok := v.Any().(error); ok {
+		data[key] = err.Error()
+		return
+	}
+	data[key] = v.Any()
+}
+
+// MCPLevel maps an slog level to the closest MCP logging level
+func MCPLevel(level slog.Level) mcp.LoggingLevel {
+	switch {
+	case level < slog.LevelInfo:
+		return mcp.LoggingLevelDebug
+	case level < slog.LevelWarn:
+		return mcp.LoggingLevelInfo
+	case level < slog.LevelError:
+		return mcp.LoggingLevelWarning
+	case level == slog.LevelError:
+		return mcp.LoggingLevelError
+	default:
+		return mcp.LoggingLevelCritical
+	}
+}
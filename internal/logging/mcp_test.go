@@ -0,0 +1,77 @@
+package logging
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"golang.org/x/exp/slog"
+)
+
+type fakeSession struct {
+	id    string
+	ch    chan mcp.JSONRPCNotification
+	level mcp.LoggingLevel
+}
+
+func (s *fakeSession) Initialize()                                         {}
+func (s *fakeSession) Initialized() bool                                   { return true }
+func (s *fakeSession) NotificationChannel() chan<- mcp.JSONRPCNotification { return s.ch }
+func (s *fakeSession) SessionID() string                                   { return s.id }
+func (s *fakeSession) SetLogLevel(level mcp.LoggingLevel)                  { s.level = level }
+func (s *fakeSession) GetLogLevel() mcp.LoggingLevel                       { return s.level }
+
+func TestMCPBridgeForwardsRecords(t *testing.T) {
+	bridge := NewMCPBridge()
+	srv := server.NewMCPServer("test", "0.0.0", server.WithLogging())
+	bridge.Attach(srv)
+
+	session := &fakeSession{id: "s1", ch: make(chan mcp.JSONRPCNotification, 10), level: mcp.LoggingLevelWarning}
+	other := &fakeSession{id: "s2", ch: make(chan mcp.JSONRPCNotification, 10), level: mcp.LoggingLevelDebug}
+	for _, s := range []*fakeSession{session, other} {
+		if err := srv.RegisterSession(context.Background(), s); err != nil {
+			t.Fatalf("RegisterSession failed: %v", err)
+		}
+	}
+	ctx := srv.WithContext(context.Background(), session)
+
+	logger := slog.New(bridge.Handler(discardHandler{}))
+	logger.InfoContext(ctx, "below the session level")
+	logger.WarnContext(ctx, "connection slow", "tool", "count_records")
+
+	select {
+	case n := <-session.ch:
+		if n.Method != "notifications/message" {
+			t.Fatalf("unexpected method %s", n.Method)
+		}
+		fields := n.Params.AdditionalFields
+		if fields["level"] != mcp.LoggingLevelWarning {
+			t.Errorf("expected warning level, got %v", fields["level"])
+		}
+		data, _ := fields["data"].(map[string]any)
+		if data["message"] != "connection slow" || data["tool"] != "count_records" {
+			t.Errorf("unexpected data %v", data)
+		}
+	default:
+		t.Fatal("expected a notification")
+	}
+	if len(session.ch) != 0 {
+		t.Fatalf("expected info record to be filtered, got %d extra notifications", len(session.ch))
+	}
+
+	session.SetLogLevel(mcp.LoggingLevelDebug)
+	logger.DebugContext(ctx, "rendering template")
+	if len(session.ch) != 1 {
+		t.Fatalf("expected 1 notification for session context, got %d", len(session.ch))
+	}
+
+	// Records without a session, e.g. from REST calls, reach no client
+	logger.Error("tool denied", "principal", "alice")
+	if len(other.ch) != 0 || len(session.ch) != 1 {
+		t.Fatalf("expected records without a session to stay local, got %d and %d", len(other.ch), len(session.ch))
+	}
+	if logger.Enabled(context.Background(), slog.LevelDebug) {
+		t.Fatal("expected debug to be disabled outside a session that asked for it")
+	}
+}
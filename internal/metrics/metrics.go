@@ -0,0 +1,101 @@
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "td_mcp"
+
+// Registry holds every td-go-mcp metric plus the Go runtime and process collectors
+var Registry = prometheus.NewRegistry()
+
+var (
+	// ToolCalls counts tool calls by tool and audit outcome
+	ToolCalls = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "tool_calls_total",
+		Help:      "Tool calls by tool and outcome (ok, error, rejected, throttled, preview, test_data).",
+	}, []string{"tool", "outcome"})
+
+	// RowsReturned observes the rows returned per tool call
+	RowsReturned = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "tool_rows_returned",
+		Help:      "Rows returned per tool call.",
+		Buckets:   []float64{0, 1, 10, 100, 1000, 10000, 100000},
+	}, []string{"tool"})
+
+	// BytesReturned observes the size of each tool result sent to the client
+	BytesReturned = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "tool_result_bytes",
+		Help:      "Size in bytes of tool results returned to clients.",
+		Buckets:   prometheus.ExponentialBuckets(256, 4, 8),
+	}, []string{"tool"})
+
+	// TestDataFallbacks counts calls answered from return_test_message because no database was connected
+	TestDataFallbacks = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "test_data_fallbacks_total",
+		Help:      "Tool calls answered with test data because no database was connected.",
+	}, []string{"tool"})
+
+	// CacheLookups counts result cache lookups by tool and result (hit, miss or bypass)
+	CacheLookups = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_lookups_total",
+		Help:      "Result cache lookups by tool and result (hit, miss, bypass).",
+	}, []string{"tool", "result"})
+
+	// PromptGets counts prompts/get requests by prompt
+	PromptGets = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "prompt_gets_total",
+		Help:      "prompts/get requests by prompt.",
+	}, []string{"prompt"})
+
+	// QueryDuration observes SQL execution latency, including fetching rows
+	QueryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "sql_query_duration_seconds",
+		Help:      "SQL execution latency by connection and outcome (ok or error).",
+		Buckets:   []float64{0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120},
+	}, []string{"connection", "outcome"})
+
+	// ActiveQueries is the number of SQL statements currently executing
+	ActiveQueries = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "sql_active_queries",
+		Help:      "SQL statements currently executing.",
+	}, []string{"connection"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		ToolCalls, RowsReturned, BytesReturned, TestDataFallbacks, CacheLookups, PromptGets,
+		QueryDuration, ActiveQueries,
+	)
+}
+
+// RegisterDBStats exports the sql.DB pool statistics for a connection
+// (go_sql_* metrics labelled db_name). Registering the same name twice is
+// not an error.
+func RegisterDBStats(name string, db *sql.DB) error {
+	err := Registry.Register(collectors.NewDBStatsCollector(db, name))
+	if _, ok := err.(prometheus.AlreadyRegisteredError); ok {
+		return nil
+	}
+	return err
+}
+
+// Handler serves the registry in the Prometheus exposition format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
@@ -0,0 +1,40 @@
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandlerExposesMetrics(t *testing.T) {
+	ToolCalls.WithLabelValues("count_records", "ok").Inc()
+	PromptGets.WithLabelValues("daily_standup").Inc()
+
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	body := rec.Body.String()
+	for _, want := range []string{
+		`td_mcp_tool_calls_total{outcome="ok",tool="count_records"} 1`,
+		`td_mcp_prompt_gets_total{prompt="daily_standup"} 1`,
+		"go_goroutines",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected metrics output to contain %q", want)
+		}
+	}
+}
+
+func TestRegisterDBStatsTwice(t *testing.T) {
+	var db sql.DB
+	if err := RegisterDBStats("teradw", &db); err != nil {
+		t.Fatalf("first registration failed: %v", err)
+	}
+	if err := RegisterDBStats("teradw", &db); err != nil {
+		t.Fatalf("second registration should be ignored, got %v", err)
+	}
+}
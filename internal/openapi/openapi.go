@@ -0,0 +1,234 @@
+package openapi
+
+import (
+	"sort"
+
+	"td_go_mcp/internal/tools"
+)
+
+// defaultTag groups tools that have no category
+const defaultTag = "tools"
+
+// Options sets the document's info and server sections
+type Options struct {
+	Title     string
+	Version   string
+	ServerURL string
+}
+
+// Generate builds an OpenAPI 3.0 document describing the REST API for the
+// given tools: POST /api/tools/{name} and POST /api/tools/{name}/preview for
+// each tool, tagged by category. Request schemas come from the tool's
+// parameters; row schemas from its output columns or return_type.
+func Generate(defs []tools.ToolDefinition, opts Options) map[string]any {
+	if opts.Title == "" {
+		opts.Title = "td-go-mcp tools"
+	}
+	if opts.ServerURL == "" {
+		opts.ServerURL = "/"
+	}
+
+	sorted := append([]tools.ToolDefinition(nil), defs...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+
+	paths := map[string]any{}
+	tagSet := map[string]struct{}{}
+	for _, def := range sorted {
+		tag := def.Category
+		if tag == "" {
+			tag = defaultTag
+		}
+		tagSet[tag] = struct{}{}
+		paths["/api/tools/"+def.Name] = map[string]any{"post": runOperation(def, tag)}
+		paths["/api/tools/"+def.Name+"/preview"] = map[string]any{"post": previewOperation(def, tag)}
+	}
+
+	tagNames := make([]string, 0, len(tagSet))
+	for tag := range tagSet {
+		tagNames = append(tagNames, tag)
+	}
+	sort.Strings(tagNames)
+	tags := make([]any, 0, len(tagNames))
+	for _, tag := range tagNames {
+		tags = append(tags, map[string]any{"name": tag})
+	}
+
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":   opts.Title,
+			"version": opts.Version,
+		},
+		"servers": []any{map[string]any{"url": opts.ServerURL}},
+		"tags":    tags,
+		"paths":   paths,
+		"components": map[string]any{
+			"schemas": map[string]any{
+				"Error": map[string]any{
+					"type":       "object",
+					"properties": map[string]any{"error": map[string]any{"type": "string"}},
+					"required":   []string{"error"},
+				},
+			},
+		},
+	}
+}
+
+func runOperation(def tools.ToolDefinition, tag string) map[string]any {
+	row := RowSchema(def)
+	result := map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"rows":      map[string]any{"type": "array", "items": row},
+			"count":     map[string]any{"type": "integer"},
+			"sql":       map[string]any{"type": "string"},
+			"source":    map[string]any{"type": "string", "enum": []string{"database", "test_message"}},
+			"cached":    map[string]any{"type": "boolean"},
+			"cached_at": map[string]any{"type": "string", "format": "date-time"},
+		},
+		"required": []string{"rows", "count", "sql", "source"},
+	}
+	if len(def.Statements) > 0 {
+		statements := map[string]any{}
+		for _, stmt := range def.Statements {
+			statements[stmt.Name] = map[string]any{"type": "array", "items": map[string]any{"type": "object"}}
+		}
+		result["properties"].(map[string]any)["results"] = map[string]any{"type": "object", "properties": statements}
+	}
+	if def.HasTransform() {
+		result["properties"].(map[string]any)["result"] = map[string]any{
+			"description":   "Output of the tool's transform: reshaped rows, or a single value",
+			"x-return-type": def.ReturnType,
+		}
+	}
+	responses := errorResponses()
+	responses["200"] = map[string]any{
+		"description": "Query results",
+		"content": map[string]any{
+			"application/json": map[string]any{"schema": result},
+			"text/csv":         map[string]any{"schema": map[string]any{"type": "string"}},
+		},
+	}
+	responses["429"] = errorResponse("Rate limited; see Retry-After")
+	responses["502"] = errorResponse("The database rejected the query")
+	responses["503"] = errorResponse("No database connection and no test data")
+	return map[string]any{
+		"operationId": def.Name,
+		"summary":     def.Description,
+		"tags":        []string{tag},
+		"requestBody": requestBody(def),
+		"responses":   responses,
+	}
+}
+
+func previewOperation(def tools.ToolDefinition, tag string) map[string]any {
+	responses := errorResponses()
+	responses["200"] = map[string]any{
+		"description": "Rendered SQL",
+		"content": map[string]any{
+			"application/json": map[string]any{"schema": map[string]any{
+				"type":       "object",
+				"properties": map[string]any{"sql": map[string]any{"type": "string"}},
+				"required":   []string{"sql"},
+			}},
+			"text/plain": map[string]any{"schema": map[string]any{"type": "string"}},
+		},
+	}
+	return map[string]any{
+		"operationId": def.Name + "_preview",
+		"summary":     "Preview the SQL for " + def.Name,
+		"tags":        []string{tag},
+		"requestBody": requestBody(def),
+		"responses":   responses,
+	}
+}
+
+func requestBody(def tools.ToolDefinition) map[string]any {
+	return map[string]any{
+		"required": len(def.Required) > 0,
+		"content": map[string]any{
+			"application/json": map[string]any{"schema": RequestSchema(def)},
+		},
+	}
+}
+
+func errorResponses() map[string]any {
+	return map[string]any{
+		"400": errorResponse("The body is not a JSON object"),
+		"403": errorResponse("The caller may not use the tool"),
+		"404": errorResponse("Unknown tool"),
+		"406": errorResponse("Unsupported Accept header"),
+		"422": errorResponse("Parameter validation failed"),
+	}
+}
+
+func errorResponse(description string) map[string]any {
+	return map[string]any{
+		"description": description,
+		"content": map[string]any{
+			"application/json": map[string]any{"schema": map[string]any{"$ref": "#/components/schemas/Error"}},
+		},
+	}
+}
+
+// RequestSchema is the JSON schema of a tool's arguments
+func RequestSchema(def tools.ToolDefinition) map[string]any {
+	properties := map[string]any{}
+	for name, param := range def.Parameters {
+		prop := map[string]any{"type": schemaType(param.Type)}
+		if param.Description != "" {
+			prop["description"] = param.Description
+		}
+		if param.Default != nil {
+			prop["default"] = param.Default
+		}
+		if param.Secret {
+			prop["format"] = "password"
+		}
+		properties[name] = prop
+	}
+	schema := map[string]any{"type": "object", "properties": properties}
+	if len(def.Required) > 0 {
+		schema["required"] = def.Required
+	}
+	return schema
+}
+
+// RowSchema is the JSON schema of one result row. Tools that list their
+// output columns get a property per column; otherwise return_type is
+// recorded as x-return-type and any properties are allowed.
+func RowSchema(def tools.ToolDefinition) map[string]any {
+	if len(def.Columns) == 0 {
+		schema := map[string]any{"type": "object", "additionalProperties": true}
+		if def.ReturnType != "" {
+			schema["x-return-type"] = def.ReturnType
+		}
+		return schema
+	}
+	properties := map[string]any{}
+	for _, col := range def.Columns {
+		prop := map[string]any{"type": schemaType(col.Type), "nullable": true}
+		if col.Description != "" {
+			prop["description"] = col.Description
+		}
+		switch col.Type {
+		case "date":
+			prop["format"] = "date"
+		case "timestamp", "datetime":
+			prop["format"] = "date-time"
+		}
+		properties[col.Name] = prop
+	}
+	return map[string]any{"type": "object", "properties": properties}
+}
+
+// schemaType maps a YAML parameter or column type to a JSON schema type
+func schemaType(t string) string {
+	switch t {
+	case "integer", "number", "boolean", "array", "object":
+		return t
+	case "decimal", "float":
+		return "number"
+	}
+	return "string"
+}
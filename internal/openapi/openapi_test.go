@@ -0,0 +1,98 @@
+package openapi
+
+import (
+	"encoding/json"
+	"testing"
+
+	"td_go_mcp/internal/tools"
+)
+
+func TestGenerate(t *testing.T) {
+	defs := []tools.ToolDefinition{
+		{
+			Name:        "count_records",
+			Description: "Count records",
+			Category:    "analytics",
+			ReturnType:  "integer",
+			Parameters: map[string]tools.Parameter{
+				"table_name": {Type: "string", Description: "Table"},
+				"limit":      {Type: "integer", Default: 100},
+			},
+			Required: []string{"table_name"},
+			Columns:  []tools.Column{{Name: "record_count", Type: "integer"}},
+		},
+		{Name: "get_user_by_id", ReturnType: "object"},
+	}
+
+	doc := Generate(defs, Options{Version: "1.0.0"})
+	// Round-trip through JSON so the assertions see what clients see
+	data, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatalf("failed to marshal spec: %v", err)
+	}
+	var spec struct {
+		OpenAPI string `json:"openapi"`
+		Tags    []struct {
+			Name string `json:"name"`
+		} `json:"tags"`
+		Paths map[string]struct {
+			Post struct {
+				OperationID string   `json:"operationId"`
+				Tags        []string `json:"tags"`
+				RequestBody struct {
+					Content map[string]struct {
+						Schema struct {
+							Required   []string                  `json:"required"`
+							Properties map[string]map[string]any `json:"properties"`
+						} `json:"schema"`
+					} `json:"content"`
+				} `json:"requestBody"`
+				Responses map[string]struct {
+					Content map[string]struct {
+						Schema struct {
+							Properties struct {
+								Rows struct {
+									Items map[string]any `json:"items"`
+								} `json:"rows"`
+							} `json:"properties"`
+						} `json:"schema"`
+					} `json:"content"`
+				} `json:"responses"`
+			} `json:"post"`
+		} `json:"paths"`
+	}
+	if err := json.Unmarshal(data, &spec); err != nil {
+		t.Fatalf("failed to unmarshal spec: %v", err)
+	}
+
+	if spec.OpenAPI != "3.0.3" {
+		t.Errorf("expected openapi 3.0.3, got %q", spec.OpenAPI)
+	}
+	if len(spec.Tags) != 2 || spec.Tags[0].Name != "analytics" || spec.Tags[1].Name != "tools" {
+		t.Errorf("expected tags analytics and tools, got %+v", spec.Tags)
+	}
+
+	op := spec.Paths["/api/tools/count_records"].Post
+	if op.OperationID != "count_records" || len(op.Tags) != 1 || op.Tags[0] != "analytics" {
+		t.Fatalf("unexpected count_records operation %+v", op)
+	}
+	req := op.RequestBody.Content["application/json"].Schema
+	if len(req.Required) != 1 || req.Required[0] != "table_name" {
+		t.Errorf("expected table_name to be required, got %v", req.Required)
+	}
+	if req.Properties["limit"]["type"] != "integer" || req.Properties["limit"]["default"] != float64(100) {
+		t.Errorf("unexpected limit schema %v", req.Properties["limit"])
+	}
+	row := op.Responses["200"].Content["application/json"].Schema.Properties.Rows.Items
+	if props, ok := row["properties"].(map[string]any); !ok || props["record_count"] == nil {
+		t.Errorf("expected record_count column in row schema, got %v", row)
+	}
+
+	if _, ok := spec.Paths["/api/tools/count_records/preview"]; !ok {
+		t.Error("expected preview path for count_records")
+	}
+	userRow := spec.Paths["/api/tools/get_user_by_id"].Post.Responses["200"].Content["application/json"].Schema.Properties.Rows.Items
+	if userRow["x-return-type"] != "object" {
+		t.Errorf("expected x-return-type object without columns, got %v", userRow)
+	}
+}
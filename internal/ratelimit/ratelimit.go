@@ -0,0 +1,373 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrLimited is wrapped by every *Error
+var ErrLimited = errors.New("rate limited")
+
+// Scopes named in an *Error
+const (
+	ScopeGlobal  = "global"
+	ScopeTool    = "tool"
+	ScopeSession = "session"
+)
+
+// Error reports which limit rejected a call and when to try again
+type Error struct {
+	Scope  string
+	Reason string
+	Retry  time.Duration
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s limit %s, retry after %s", ErrLimited, e.Scope, e.Reason, e.Retry.Round(time.Second))
+}
+
+func (e *Error) Unwrap() error { return ErrLimited }
+
+// RetryAfter is how long the caller should wait before retrying
+func (e *Error) RetryAfter() time.Duration { return e.Retry }
+
+// Limit is a token-bucket rate and a cap on concurrent executions. The
+// zero value is unlimited.
+type Limit struct {
+	// Rate is "N/s", "N/m" or "N/h"; the bucket holds N tokens
+	Rate           string `yaml:"rate"`
+	MaxConcurrency int    `yaml:"max_concurrency"`
+}
+
+// ParseRate parses "N/s", "N/m" or "N/h" into tokens per second and a burst
+// of N. An empty string is unlimited (0, 0).
+func ParseRate(s string) (perSecond float64, burst int, err error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, 0, nil
+	}
+	count, unit, ok := strings.Cut(s, "/")
+	n, convErr := strconv.Atoi(strings.TrimSpace(count))
+	if !ok || convErr != nil || n <= 0 {
+		return 0, 0, fmt.Errorf("invalid rate %q (use N/s, N/m or N/h)", s)
+	}
+	var per time.Duration
+	switch strings.TrimSpace(unit) {
+	case "s", "sec", "second":
+		per = time.Second
+	case "m", "min", "minute":
+		per = time.Minute
+	case "h", "hour":
+		per = time.Hour
+	default:
+		return 0, 0, fmt.Errorf("invalid rate %q (use N/s, N/m or N/h)", s)
+	}
+	return float64(n) / per.Seconds(), n, nil
+}
+
+// Validate checks that l.Rate parses
+func (l Limit) Validate() error {
+	if l.MaxConcurrency < 0 {
+		return fmt.Errorf("max_concurrency must not be negative")
+	}
+	_, _, err := ParseRate(l.Rate)
+	return err
+}
+
+// Queue bounds how calls over a limit wait. A zero Timeout fails fast.
+type Queue struct {
+	Timeout time.Duration
+	// MaxWaiting is how many calls may wait on one limiter; 0 is unbounded
+	MaxWaiting int
+}
+
+// limiter enforces one Limit
+type limiter struct {
+	scope string
+	rate  float64
+	burst float64
+	slots chan struct{}
+
+	mu      sync.Mutex
+	tokens  float64
+	last    time.Time
+	waiting int
+	used    time.Time
+}
+
+func newLimiter(scope string, l Limit) (*limiter, error) {
+	rate, burst, err := ParseRate(l.Rate)
+	if err != nil {
+		return nil, err
+	}
+	lim := &limiter{scope: scope, rate: rate, burst: float64(burst), tokens: float64(burst), last: time.Now()}
+	if l.MaxConcurrency > 0 {
+		lim.slots = make(chan struct{}, l.MaxConcurrency)
+	}
+	return lim, nil
+}
+
+// acquire takes a token and, when slot is set, a concurrency slot, waiting
+// until deadline, and returns the function that frees the slot. The token
+// is refunded if the slot cannot be had.
+func (l *limiter) acquire(ctx context.Context, q Queue, deadline time.Time, slot bool) (release func(), err error) {
+	if !l.enqueue(q) {
+		return nil, &Error{Scope: l.scope, Reason: "queue is full", Retry: queueRetry(q)}
+	}
+	defer l.dequeue()
+
+	if err := l.takeToken(ctx, deadline); err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			l.refund()
+		}
+	}()
+	if l.slots == nil || !slot {
+		return func() {}, nil
+	}
+	select {
+	case l.slots <- struct{}{}:
+		return func() { <-l.slots }, nil
+	default:
+	}
+	if q.Timeout <= 0 {
+		return nil, &Error{Scope: l.scope, Reason: fmt.Sprintf("of %d concurrent calls reached", cap(l.slots)), Retry: time.Second}
+	}
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+	select {
+	case l.slots <- struct{}{}:
+		return func() { <-l.slots }, nil
+	case <-timer.C:
+		return nil, &Error{Scope: l.scope, Reason: fmt.Sprintf("of %d concurrent calls reached", cap(l.slots)), Retry: time.Second}
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// refund returns the token of a call that was rejected after taking it
+func (l *limiter) refund() {
+	if l.rate == 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.tokens = math.Min(l.burst, l.tokens+1)
+}
+
+// takeToken reserves a token, sleeping until it is available if that is
+// before deadline
+func (l *limiter) takeToken(ctx context.Context, deadline time.Time) error {
+	if l.rate == 0 {
+		return nil
+	}
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens = math.Min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+	l.tokens--
+	var wait time.Duration
+	if l.tokens < 0 {
+		wait = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+	if wait > 0 && now.Add(wait).After(deadline) {
+		l.tokens++
+		l.mu.Unlock()
+		return &Error{Scope: l.scope, Reason: "rate exceeded", Retry: wait}
+	}
+	l.mu.Unlock()
+	if wait == 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		l.refund()
+		return ctx.Err()
+	}
+}
+
+func (l *limiter) enqueue(q Queue) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.used = time.Now()
+	if q.MaxWaiting > 0 && l.waiting >= q.MaxWaiting {
+		return false
+	}
+	l.waiting++
+	return true
+}
+
+func (l *limiter) dequeue() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.waiting--
+}
+
+// queueRetry estimates when a full queue will have room
+func queueRetry(q Queue) time.Duration {
+	if q.Timeout > 0 {
+		return q.Timeout
+	}
+	return time.Second
+}
+
+// idleSince reports whether the limiter is unused since t
+func (l *limiter) idleSince(t time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.waiting == 0 && l.used.Before(t) && (l.slots == nil || len(l.slots) == 0)
+}
+
+// Manager applies the global, per-tool and per-session limits to tool calls
+type Manager struct {
+	queue   Queue
+	global  *limiter
+	session Limit
+
+	mu       sync.Mutex
+	tools    map[string]*limiter
+	sessions map[string]*limiter
+}
+
+// NewManager creates a Manager. perSession applies to each client session separately.
+func NewManager(global, perSession Limit, queue Queue) (*Manager, error) {
+	m := &Manager{queue: queue, session: perSession, tools: map[string]*limiter{}, sessions: map[string]*limiter{}}
+	if err := perSession.Validate(); err != nil {
+		return nil, fmt.Errorf("per-session limit: %w", err)
+	}
+	if global != (Limit{}) {
+		g, err := newLimiter(ScopeGlobal, global)
+		if err != nil {
+			return nil, fmt.Errorf("global limit: %w", err)
+		}
+		m.global = g
+	}
+	return m, nil
+}
+
+// SetTool sets a tool's limit; a zero Limit removes it
+func (m *Manager) SetTool(tool string, l Limit) error {
+	if m == nil {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if l == (Limit{}) {
+		delete(m.tools, tool)
+		return nil
+	}
+	lim, err := newLimiter(ScopeTool, l)
+	if err != nil {
+		return fmt.Errorf("tool %s: %w", tool, err)
+	}
+	m.tools[tool] = lim
+	return nil
+}
+
+// Acquire admits a call to tool from session (empty when the caller has no
+// session), narrowest limit first, waiting at most the queue timeout in all.
+// It returns a function that must be called when the call finishes, or an
+// *Error when a limit rejects it; tokens taken by the limits before the one
+// that rejected it are refunded.
+func (m *Manager) Acquire(ctx context.Context, tool, session string) (func(), error) {
+	return m.acquire(ctx, tool, session, false)
+}
+
+// AcquireStep admits a call made within another call that already holds
+// the session's and the global concurrency slots, such as a pipeline step.
+// It takes a token from every limit but a slot only from the tool's, so
+// steps count against every rate without waiting on their own caller.
+func (m *Manager) AcquireStep(ctx context.Context, tool, session string) (func(), error) {
+	return m.acquire(ctx, tool, session, true)
+}
+
+func (m *Manager) acquire(ctx context.Context, tool, session string, step bool) (func(), error) {
+	if m == nil {
+		return func() {}, nil
+	}
+	var limiters []*limiter
+	if lim := m.sessionLimiter(session); lim != nil {
+		limiters = append(limiters, lim)
+	}
+	m.mu.Lock()
+	if lim := m.tools[tool]; lim != nil {
+		limiters = append(limiters, lim)
+	}
+	m.mu.Unlock()
+	if m.global != nil {
+		limiters = append(limiters, m.global)
+	}
+
+	deadline := time.Now().Add(m.queue.Timeout)
+	releases := make([]func(), 0, len(limiters))
+	release := func() {
+		for i := len(releases) - 1; i >= 0; i-- {
+			releases[i]()
+		}
+	}
+	for i, lim := range limiters {
+		r, err := lim.acquire(ctx, m.queue, deadline, !step || lim.scope == ScopeTool)
+		if err != nil {
+			release()
+			for _, earlier := range limiters[:i] {
+				earlier.refund()
+			}
+			return nil, err
+		}
+		releases = append(releases, r)
+	}
+	return release, nil
+}
+
+func (m *Manager) sessionLimiter(session string) *limiter {
+	if session == "" || m.session == (Limit{}) {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	lim, ok := m.sessions[session]
+	if !ok {
+		// The limit was validated in NewManager
+		lim, _ = newLimiter(ScopeSession, m.session)
+		m.sessions[session] = lim
+	}
+	return lim
+}
+
+// Forget drops a session's limiter when the session ends
+func (m *Manager) Forget(session string) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.sessions, session)
+}
+
+// Prune drops session limiters idle for longer than maxIdle. Sessions
+// without an end event (REST callers) are cleaned up this way.
+func (m *Manager) Prune(maxIdle time.Duration) {
+	if m == nil {
+		return
+	}
+	cutoff := time.Now().Add(-maxIdle)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for session, lim := range m.sessions {
+		if lim.idleSince(cutoff) {
+			delete(m.sessions, session)
+		}
+	}
+}
@@ -0,0 +1,229 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseRate(t *testing.T) {
+	cases := []struct {
+		in    string
+		rate  float64
+		burst int
+		ok    bool
+	}{
+		{"", 0, 0, true},
+		{"10/s", 10, 10, true},
+		{"30/m", 0.5, 30, true},
+		{"3600/h", 1, 3600, true},
+		{"10", 0, 0, false},
+		{"0/s", 0, 0, false},
+		{"5/day", 0, 0, false},
+	}
+	for _, tc := range cases {
+		rate, burst, err := ParseRate(tc.in)
+		if tc.ok != (err == nil) || rate != tc.rate || burst != tc.burst {
+			t.Fatalf("ParseRate(%q) = %v, %v, %v", tc.in, rate, burst, err)
+		}
+	}
+}
+
+func TestRateFailsFast(t *testing.T) {
+	m, err := NewManager(Limit{}, Limit{}, Queue{})
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	if err := m.SetTool("count_records", Limit{Rate: "2/m"}); err != nil {
+		t.Fatalf("SetTool: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		release, err := m.Acquire(context.Background(), "count_records", "s1")
+		if err != nil {
+			t.Fatalf("call %d: %v", i, err)
+		}
+		release()
+	}
+	_, err = m.Acquire(context.Background(), "count_records", "s1")
+	var limitErr *Error
+	if !errors.As(err, &limitErr) || limitErr.Scope != ScopeTool || limitErr.RetryAfter() <= 0 {
+		t.Fatalf("expected tool rate limit with retry hint, got %v", err)
+	}
+	if !errors.Is(err, ErrLimited) {
+		t.Fatal("expected error to wrap ErrLimited")
+	}
+	if release, err := m.Acquire(context.Background(), "other_tool", "s1"); err != nil {
+		t.Fatalf("other tools must not be limited: %v", err)
+	} else {
+		release()
+	}
+}
+
+func TestConcurrencyQueue(t *testing.T) {
+	m, err := NewManager(Limit{}, Limit{MaxConcurrency: 1}, Queue{Timeout: 200 * time.Millisecond, MaxWaiting: 2})
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	release, err := m.Acquire(context.Background(), "count_records", "s1")
+	if err != nil {
+		t.Fatalf("first call: %v", err)
+	}
+
+	// Another session is not affected by s1's limit
+	other, err := m.Acquire(context.Background(), "count_records", "s2")
+	if err != nil {
+		t.Fatalf("second session: %v", err)
+	}
+	other()
+
+	// A queued call gets the slot when it is freed
+	done := make(chan error, 1)
+	go func() {
+		r, err := m.Acquire(context.Background(), "count_records", "s1")
+		if err == nil {
+			r()
+		}
+		done <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	release()
+	if err := <-done; err != nil {
+		t.Fatalf("queued call: %v", err)
+	}
+
+	// A call that waits past the timeout is rejected
+	release, _ = m.Acquire(context.Background(), "count_records", "s1")
+	defer release()
+	start := time.Now()
+	_, err = m.Acquire(context.Background(), "count_records", "s1")
+	var limitErr *Error
+	if !errors.As(err, &limitErr) || limitErr.Scope != ScopeSession {
+		t.Fatalf("expected session concurrency limit, got %v", err)
+	}
+	if time.Since(start) < 150*time.Millisecond {
+		t.Fatal("expected the call to wait for the queue timeout")
+	}
+}
+
+func TestGlobalLimitAndForget(t *testing.T) {
+	m, err := NewManager(Limit{MaxConcurrency: 1}, Limit{Rate: "1/h"}, Queue{})
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	release, err := m.Acquire(context.Background(), "a", "s1")
+	if err != nil {
+		t.Fatalf("first call: %v", err)
+	}
+	var limitErr *Error
+	if _, err := m.Acquire(context.Background(), "b", "s2"); !errors.As(err, &limitErr) || limitErr.Scope != ScopeGlobal {
+		t.Fatalf("expected global limit, got %v", err)
+	}
+	release()
+
+	if _, err := m.Acquire(context.Background(), "a", "s1"); !errors.As(err, &limitErr) || limitErr.Scope != ScopeSession {
+		t.Fatalf("expected session rate limit, got %v", err)
+	}
+	m.Forget("s1")
+	if release, err := m.Acquire(context.Background(), "a", "s1"); err != nil {
+		t.Fatalf("expected a fresh limiter after Forget: %v", err)
+	} else {
+		release()
+	}
+}
+
+func TestRejectedCallsRefundTokens(t *testing.T) {
+	m, err := NewManager(Limit{MaxConcurrency: 1}, Limit{Rate: "2/m"}, Queue{})
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	if err := m.SetTool("count_records", Limit{Rate: "2/m", MaxConcurrency: 1}); err != nil {
+		t.Fatalf("SetTool: %v", err)
+	}
+	release, err := m.Acquire(context.Background(), "count_records", "s1")
+	if err != nil {
+		t.Fatalf("first call: %v", err)
+	}
+	// Rejected by the tool's concurrency cap, and then by the global one
+	var limitErr *Error
+	if _, err := m.Acquire(context.Background(), "count_records", "s1"); !errors.As(err, &limitErr) || limitErr.Scope != ScopeTool {
+		t.Fatalf("expected tool concurrency limit, got %v", err)
+	}
+	if _, err := m.Acquire(context.Background(), "other_tool", "s1"); !errors.As(err, &limitErr) || limitErr.Scope != ScopeGlobal {
+		t.Fatalf("expected global concurrency limit, got %v", err)
+	}
+	release()
+
+	// Only the first call spent a token of s1's and the tool's 2/m
+	release, err = m.Acquire(context.Background(), "count_records", "s1")
+	if err != nil {
+		t.Fatalf("expected rejected calls to refund their tokens: %v", err)
+	}
+	release()
+}
+
+func TestQueueTimeoutBoundsTheWholeCall(t *testing.T) {
+	const timeout = 150 * time.Millisecond
+	m, err := NewManager(Limit{MaxConcurrency: 1}, Limit{Rate: "10/s"}, Queue{Timeout: timeout})
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	// Drain s1's bucket so its next call waits ~100ms for a token
+	for i := 0; i < 10; i++ {
+		release, err := m.Acquire(context.Background(), "a", "s1")
+		if err != nil {
+			t.Fatalf("call %d: %v", i, err)
+		}
+		release()
+	}
+	hold, err := m.Acquire(context.Background(), "a", "s2")
+	if err != nil {
+		t.Fatalf("holding call: %v", err)
+	}
+	defer hold()
+
+	// The token wait uses most of the timeout; the global slot wait gets the rest
+	start := time.Now()
+	_, err = m.Acquire(context.Background(), "a", "s1")
+	var limitErr *Error
+	if !errors.As(err, &limitErr) || limitErr.Scope != ScopeGlobal {
+		t.Fatalf("expected global concurrency limit, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > timeout+50*time.Millisecond {
+		t.Fatalf("expected the call to give up after %v in all, took %v", timeout, elapsed)
+	}
+}
+
+func TestAcquireStep(t *testing.T) {
+	m, err := NewManager(Limit{MaxConcurrency: 1}, Limit{Rate: "3/m", MaxConcurrency: 1}, Queue{})
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	if err := m.SetTool("get_user", Limit{Rate: "1/m"}); err != nil {
+		t.Fatalf("SetTool: %v", err)
+	}
+	// The pipeline call holds the session's and the global slots
+	pipeline, err := m.Acquire(context.Background(), "user_flow", "s1")
+	if err != nil {
+		t.Fatalf("pipeline call: %v", err)
+	}
+	defer pipeline()
+
+	step, err := m.AcquireStep(context.Background(), "get_user", "s1")
+	if err != nil {
+		t.Fatalf("first step must not wait on its own pipeline's slots: %v", err)
+	}
+	step()
+	var limitErr *Error
+	if _, err := m.AcquireStep(context.Background(), "get_user", "s1"); !errors.As(err, &limitErr) || limitErr.Scope != ScopeTool {
+		t.Fatalf("expected the step tool's rate limit, got %v", err)
+	}
+	if release, err := m.AcquireStep(context.Background(), "list_sessions", "s1"); err != nil {
+		t.Fatalf("expected the session's third token: %v", err)
+	} else {
+		release()
+	}
+	if _, err := m.AcquireStep(context.Background(), "list_sessions", "s1"); !errors.As(err, &limitErr) || limitErr.Scope != ScopeSession {
+		t.Fatalf("expected steps to spend the session's rate, got %v", err)
+	}
+}
@@ -0,0 +1,168 @@
+package rotate
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// backupTimeFormat is appended to rotated file names: audit-2006-01-02T15-04-05.000.jsonl
+const backupTimeFormat = "2006-01-02T15-04-05.000"
+
+// Options controls when a File rotates and how many old files are kept.
+// Zero values disable the corresponding limit.
+type Options struct {
+	MaxSizeMB  int
+	MaxAgeDays int
+	MaxBackups int
+	// Interval rotates the file once it has been open this long
+	Interval time.Duration
+}
+
+// File is an append-only io.WriteCloser that rotates the underlying file
+// once it grows past MaxSizeMB or is older than Interval, and prunes old
+// backups by count and age
+type File struct {
+	path string
+	opts Options
+
+	mu     sync.Mutex
+	f      *os.File
+	size   int64
+	opened time.Time
+	now    func() time.Time
+}
+
+// Open opens (or creates) path for appending, creating parent directories as needed
+func Open(path string, opts Options) (*File, error) {
+	file := &File{path: path, opts: opts, now: time.Now}
+	if err := file.open(); err != nil {
+		return nil, err
+	}
+	file.prune()
+	return file, nil
+}
+
+// Write appends p, rotating first if p would push the file past MaxSizeMB
+// or the file has been open longer than Interval
+func (r *File) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.f == nil {
+		return 0, fmt.Errorf("rotate: file %s is closed", r.path)
+	}
+	max := int64(r.opts.MaxSizeMB) * 1024 * 1024
+	tooBig := max > 0 && r.size > 0 && r.size+int64(len(p)) > max
+	tooOld := r.opts.Interval > 0 && r.size > 0 && r.now().Sub(r.opened) >= r.opts.Interval
+	if tooBig || tooOld {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.f.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// Close closes the current file
+func (r *File) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.f == nil {
+		return nil
+	}
+	err := r.f.Close()
+	r.f = nil
+	return err
+}
+
+func (r *File) open() error {
+	if err := os.MkdirAll(filepath.Dir(r.path), 0755); err != nil {
+		return fmt.Errorf("rotate: failed to create directory: %w", err)
+	}
+	f, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("rotate: failed to open %s: %w", r.path, err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("rotate: failed to stat %s: %w", r.path, err)
+	}
+	r.f = f
+	r.size = info.Size()
+	r.opened = r.now()
+	if r.size > 0 {
+		// Resume the interval from when the existing file was last written
+		r.opened = info.ModTime()
+	}
+	return nil
+}
+
+func (r *File) rotate() error {
+	if err := r.f.Close(); err != nil {
+		return fmt.Errorf("rotate: failed to close %s: %w", r.path, err)
+	}
+	r.f = nil
+	ext := filepath.Ext(r.path)
+	backup := strings.TrimSuffix(r.path, ext) + "-" + r.now().Format(backupTimeFormat) + ext
+	if err := os.Rename(r.path, backup); err != nil {
+		return fmt.Errorf("rotate: failed to rename %s: %w", r.path, err)
+	}
+	if err := r.open(); err != nil {
+		return err
+	}
+	r.prune()
+	return nil
+}
+
+// prune removes backups beyond MaxBackups and older than MaxAgeDays
+func (r *File) prune() {
+	if r.opts.MaxBackups <= 0 && r.opts.MaxAgeDays <= 0 {
+		return
+	}
+	backups := r.backups()
+	cutoff := r.now().Add(-time.Duration(r.opts.MaxAgeDays) * 24 * time.Hour)
+	for i, b := range backups {
+		tooMany := r.opts.MaxBackups > 0 && i >= r.opts.MaxBackups
+		tooOld := r.opts.MaxAgeDays > 0 && b.when.Before(cutoff)
+		if tooMany || tooOld {
+			_ = os.Remove(b.path)
+		}
+	}
+}
+
+type backup struct {
+	path string
+	when time.Time
+}
+
+// backups lists rotated files for r.path, newest first
+func (r *File) backups() []backup {
+	ext := filepath.Ext(r.path)
+	prefix := filepath.Base(strings.TrimSuffix(r.path, ext)) + "-"
+	entries, err := os.ReadDir(filepath.Dir(r.path))
+	if err != nil {
+		return nil
+	}
+	var result []backup
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, ext) {
+			continue
+		}
+		stamp := strings.TrimSuffix(strings.TrimPrefix(name, prefix), ext)
+		when, err := time.ParseInLocation(backupTimeFormat, stamp, time.Local)
+		if err != nil {
+			continue
+		}
+		result = append(result, backup{path: filepath.Join(filepath.Dir(r.path), name), when: when})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].when.After(result[j].when) })
+	return result
+}
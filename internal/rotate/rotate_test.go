@@ -0,0 +1,85 @@
+package rotate
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileRotatesAndPrunes(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "audit.jsonl")
+
+	f, err := Open(path, Options{MaxSizeMB: 1, MaxBackups: 2})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer f.Close()
+
+	clock := time.Date(2025, 1, 1, 0, 0, 0, 0, time.Local)
+	f.now = func() time.Time {
+		clock = clock.Add(time.Second)
+		return clock
+	}
+
+	chunk := bytes.Repeat([]byte("x"), 600*1024)
+	for i := 0; i < 8; i++ {
+		if _, err := f.Write(chunk); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir failed: %v", err)
+	}
+	// Current file plus at most two backups
+	if len(entries) != 3 {
+		names := make([]string, len(entries))
+		for i, e := range entries {
+			names[i] = e.Name()
+		}
+		t.Fatalf("expected 3 files, got %v", names)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	if info.Size() > 1024*1024 {
+		t.Fatalf("current file exceeds max size: %d", info.Size())
+	}
+}
+
+func TestFileRotatesOnInterval(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "mcp.log")
+
+	clock := time.Date(2025, 1, 1, 0, 0, 0, 0, time.Local)
+	f := &File{path: path, opts: Options{Interval: time.Hour}, now: func() time.Time { return clock }}
+	if err := f.open(); err != nil {
+		t.Fatalf("open failed: %v", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write([]byte("first\n")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	clock = clock.Add(2 * time.Hour)
+	if _, err := f.Write([]byte("second\n")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if string(data) != "second\n" {
+		t.Fatalf("expected rotated file to contain only the second write, got %q", data)
+	}
+	if backups := f.backups(); len(backups) != 1 {
+		t.Fatalf("expected 1 backup, got %d", len(backups))
+	}
+}
@@ -0,0 +1,270 @@
+package server
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Errors a ToolRunner wraps so the API can pick the HTTP status
+var (
+	ErrForbidden           = errors.New("forbidden")                         // 403
+	ErrToolNotFound        = errors.New("tool not found")                    // 404
+	ErrInvalidArguments    = errors.New("invalid arguments")                 // 422
+	ErrRateLimited         = errors.New("too many requests")                 // 429
+	ErrDatabase            = errors.New("database error")                    // 502
+	ErrDatabaseUnavailable = errors.New("database connection not available") // 503
+)
+
+// ToolRunner runs the YAML tools behind the REST API
+type ToolRunner interface {
+	// Tools lists the tools the caller in ctx may run
+	Tools(ctx context.Context) []APITool
+	RunTool(ctx context.Context, name string, args map[string]any) (*ToolResult, error)
+	PreviewTool(ctx context.Context, name string, args map[string]any) (string, error)
+}
+
+// APITool describes a tool for GET /api/tools
+type APITool struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	InputSchema any    `json:"input_schema"`
+	Source      string `json:"source,omitempty"`
+}
+
+// ToolResult is the response to POST /api/tools/{name}
+type ToolResult struct {
+	Rows  []map[string]any `json:"rows"`
+	Count int              `json:"count"`
+	SQL   string           `json:"sql"`
+	// Source is "database", or "test_message" when no database is connected
+	Source string `json:"source"`
+	// Cached is set when the rows came from the result cache, stored at CachedAt
+	Cached   bool       `json:"cached,omitempty"`
+	CachedAt *time.Time `json:"cached_at,omitempty"`
+	// Results holds each statement's rows for a multi-statement tool; Rows
+	// are then the last statement's
+	Results map[string][]map[string]any `json:"results,omitempty"`
+	// Result is the output of the tool's transform, when it declares one:
+	// reshaped rows or a single value
+	Result any `json:"result,omitempty"`
+}
+
+// RegisterAPIRoutes mounts the REST API for YAML tools:
+//
+//	GET  /api/tools                 definitions and input schemas of the tools the caller may run
+//	POST /api/tools/{name}          run a tool with a JSON object of arguments
+//	POST /api/tools/{name}/preview  render the tool's SQL without running it
+//
+// Results are JSON, or CSV (text/plain for previews) when the Accept header asks for it.
+func RegisterAPIRoutes(mux *http.ServeMux, runner ToolRunner) {
+	mux.HandleFunc("GET /api/tools", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]any{"tools": runner.Tools(r.Context())})
+	})
+	mux.HandleFunc("POST /api/tools/{name}", func(w http.ResponseWriter, r *http.Request) {
+		handleRunTool(w, r, runner)
+	})
+	mux.HandleFunc("POST /api/tools/{name}/preview", func(w http.ResponseWriter, r *http.Request) {
+		handlePreviewTool(w, r, runner)
+	})
+}
+
+func handleRunTool(w http.ResponseWriter, r *http.Request, runner ToolRunner) {
+	format := negotiate(r, "application/json", "text/csv")
+	if format == "" {
+		writeError(w, http.StatusNotAcceptable, errors.New("supported formats: application/json, text/csv"))
+		return
+	}
+	args, err := readArguments(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	result, err := runner.RunTool(r.Context(), r.PathValue("name"), args)
+	if err != nil {
+		writeError(w, statusFor(err), err)
+		return
+	}
+	if format == "text/csv" {
+		rows := result.Rows
+		if reshaped, ok := result.Result.([]map[string]any); ok {
+			rows = reshaped
+		}
+		writeCSV(w, rows)
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+func handlePreviewTool(w http.ResponseWriter, r *http.Request, runner ToolRunner) {
+	format := negotiate(r, "application/json", "text/plain")
+	if format == "" {
+		writeError(w, http.StatusNotAcceptable, errors.New("supported formats: application/json, text/plain"))
+		return
+	}
+	args, err := readArguments(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	sql, err := runner.PreviewTool(r.Context(), r.PathValue("name"), args)
+	if err != nil {
+		writeError(w, statusFor(err), err)
+		return
+	}
+	if format == "text/plain" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(sql))
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"sql": sql})
+}
+
+// readArguments decodes the request body as a JSON object; an empty body means no arguments
+func readArguments(r *http.Request) (map[string]any, error) {
+	args := map[string]any{}
+	if r.Body == nil {
+		return args, nil
+	}
+	dec := json.NewDecoder(r.Body)
+	dec.UseNumber()
+	if err := dec.Decode(&args); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("request body must be a JSON object of tool arguments: %v", err)
+	}
+	if args == nil {
+		args = map[string]any{} // a body of null
+	}
+	// Tools validate numbers as float64, matching what MCP clients send
+	for k, v := range args {
+		if n, ok := v.(json.Number); ok {
+			f, err := n.Float64()
+			if err != nil {
+				return nil, fmt.Errorf("argument %s: %v", k, err)
+			}
+			args[k] = f
+		}
+	}
+	return args, nil
+}
+
+func statusFor(err error) int {
+	switch {
+	case errors.Is(err, ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, ErrToolNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrInvalidArguments):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, ErrRateLimited):
+		return http.StatusTooManyRequests
+	case errors.Is(err, ErrDatabaseUnavailable):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, ErrDatabase):
+		return http.StatusBadGateway
+	}
+	return http.StatusInternalServerError
+}
+
+// negotiate returns the first offered media type the Accept header allows,
+// preferring higher q values, or "" if none is acceptable. A missing Accept
+// header accepts the first offer.
+func negotiate(r *http.Request, offers ...string) string {
+	accept := r.Header.Get("Accept")
+	if strings.TrimSpace(accept) == "" {
+		return offers[0]
+	}
+	best, bestQ := "", 0.0
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			parsed, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		for _, offer := range offers {
+			if q > bestQ && mediaMatches(mediaType, offer) {
+				best, bestQ = offer, q
+				break
+			}
+		}
+	}
+	return best
+}
+
+func mediaMatches(pattern, offer string) bool {
+	if pattern == "*/*" || pattern == offer {
+		return true
+	}
+	if prefix, ok := strings.CutSuffix(pattern, "/*"); ok {
+		return strings.HasPrefix(offer, prefix+"/")
+	}
+	return false
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// retryAfter is implemented by errors that know when the call may be retried
+type retryAfter interface {
+	RetryAfter() time.Duration
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	var retry retryAfter
+	if errors.As(err, &retry) {
+		seconds := int(math.Ceil(retry.RetryAfter().Seconds()))
+		w.Header().Set("Retry-After", strconv.Itoa(max(seconds, 1)))
+	}
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+// writeCSV writes rows with a header of their column names in sorted order
+func writeCSV(w http.ResponseWriter, rows []map[string]any) {
+	columnSet := map[string]struct{}{}
+	for _, row := range rows {
+		for col := range row {
+			columnSet[col] = struct{}{}
+		}
+	}
+	columns := make([]string, 0, len(columnSet))
+	for col := range columnSet {
+		columns = append(columns, col)
+	}
+	sort.Strings(columns)
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	cw := csv.NewWriter(w)
+	_ = cw.Write(columns)
+	record := make([]string, len(columns))
+	for _, row := range rows {
+		for i, col := range columns {
+			record[i] = ""
+			if v, ok := row[col]; ok && v != nil {
+				record[i] = fmt.Sprint(v)
+			}
+		}
+		_ = cw.Write(record)
+	}
+	cw.Flush()
+}
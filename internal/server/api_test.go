@@ -0,0 +1,152 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fakeRunner struct{}
+
+type busyError struct{}
+
+func (busyError) Error() string             { return "tool limit of 1 concurrent calls reached" }
+func (busyError) RetryAfter() time.Duration { return 1500 * time.Millisecond }
+
+func (fakeRunner) Tools(ctx context.Context) []APITool {
+	return []APITool{{Name: "count_records", Description: "Count records"}}
+}
+
+func (fakeRunner) RunTool(ctx context.Context, name string, args map[string]any) (*ToolResult, error) {
+	switch {
+	case name == "busy_tool":
+		return nil, fmt.Errorf("%w: %w", ErrRateLimited, busyError{})
+	case name == "run_admin_report":
+		return nil, fmt.Errorf("%w: guest may not call %s", ErrForbidden, name)
+	case name != "count_records":
+		return nil, fmt.Errorf("%w: %s", ErrToolNotFound, name)
+	case args["table_name"] == nil:
+		return nil, fmt.Errorf("%w: missing required parameter: table_name", ErrInvalidArguments)
+	case args["table_name"] == "broken":
+		return nil, fmt.Errorf("%w: table does not exist", ErrDatabase)
+	case args["table_name"] == "by_status":
+		return &ToolResult{
+			Rows:   []map[string]any{{"status": "open", "n": 2}, {"status": "open", "n": 3}},
+			Count:  2,
+			SQL:    "SELECT status, n FROM t",
+			Source: "database",
+			Result: []map[string]any{{"status": "open", "total": 5}},
+		}, nil
+	}
+	return &ToolResult{
+		Rows:   []map[string]any{{"table_name": args["table_name"], "record_count": 1250}},
+		Count:  1,
+		SQL:    "SELECT COUNT(*) FROM users",
+		Source: "database",
+	}, nil
+}
+
+func (fakeRunner) PreviewTool(ctx context.Context, name string, args map[string]any) (string, error) {
+	args["__preview"] = true // as the real runner does
+	return "SELECT COUNT(*) FROM users", nil
+}
+
+func TestAPIRoutes(t *testing.T) {
+	mux := http.NewServeMux()
+	RegisterAPIRoutes(mux, fakeRunner{})
+
+	do := func(method, target, accept, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("list", func(t *testing.T) {
+		rec := do(http.MethodGet, "/api/tools", "", "")
+		if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"count_records"`) {
+			t.Fatalf("expected tool list, got %d %s", rec.Code, rec.Body.String())
+		}
+	})
+
+	t.Run("run json", func(t *testing.T) {
+		rec := do(http.MethodPost, "/api/tools/count_records", "", `{"table_name":"users"}`)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+		var result ToolResult
+		if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+			t.Fatalf("failed to unmarshal response: %v", err)
+		}
+		if result.Count != 1 || result.Rows[0]["record_count"] != float64(1250) {
+			t.Fatalf("unexpected result %+v", result)
+		}
+	})
+
+	t.Run("run csv", func(t *testing.T) {
+		rec := do(http.MethodPost, "/api/tools/count_records", "text/csv, application/json;q=0.5", `{"table_name":"users"}`)
+		want := "record_count,table_name\n1250,users\n"
+		if rec.Code != http.StatusOK || rec.Body.String() != want {
+			t.Fatalf("expected CSV %q, got %d %q", want, rec.Code, rec.Body.String())
+		}
+	})
+
+	t.Run("run csv transformed", func(t *testing.T) {
+		rec := do(http.MethodPost, "/api/tools/count_records", "text/csv", `{"table_name":"by_status"}`)
+		want := "status,total\nopen,5\n"
+		if rec.Code != http.StatusOK || rec.Body.String() != want {
+			t.Fatalf("expected CSV of the transformed rows %q, got %d %q", want, rec.Code, rec.Body.String())
+		}
+	})
+
+	t.Run("preview text", func(t *testing.T) {
+		rec := do(http.MethodPost, "/api/tools/count_records/preview", "text/plain", `{"table_name":"users"}`)
+		if rec.Code != http.StatusOK || rec.Body.String() != "SELECT COUNT(*) FROM users" {
+			t.Fatalf("expected SQL text, got %d %q", rec.Code, rec.Body.String())
+		}
+	})
+
+	t.Run("preview null body", func(t *testing.T) {
+		rec := do(http.MethodPost, "/api/tools/count_records/preview", "text/plain", `null`)
+		if rec.Code != http.StatusOK || rec.Body.String() != "SELECT COUNT(*) FROM users" {
+			t.Fatalf("expected a null body to mean no arguments, got %d %q", rec.Code, rec.Body.String())
+		}
+	})
+
+	statuses := []struct {
+		name, target, accept, body string
+		want                       int
+	}{
+		{"unknown tool", "/api/tools/nope", "", `{}`, http.StatusNotFound},
+		{"forbidden", "/api/tools/run_admin_report", "", `{}`, http.StatusForbidden},
+		{"validation error", "/api/tools/count_records", "", `{}`, http.StatusUnprocessableEntity},
+		{"database error", "/api/tools/count_records", "", `{"table_name":"broken"}`, http.StatusBadGateway},
+		{"malformed body", "/api/tools/count_records", "", `[1,2]`, http.StatusBadRequest},
+		{"null body", "/api/tools/count_records", "", `null`, http.StatusUnprocessableEntity},
+		{"not acceptable", "/api/tools/count_records", "application/xml", `{"table_name":"users"}`, http.StatusNotAcceptable},
+		{"rate limited", "/api/tools/busy_tool", "", `{}`, http.StatusTooManyRequests},
+	}
+	for _, tt := range statuses {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := do(http.MethodPost, tt.target, tt.accept, tt.body)
+			if rec.Code != tt.want {
+				t.Fatalf("expected %d, got %d: %s", tt.want, rec.Code, rec.Body.String())
+			}
+		})
+	}
+
+	t.Run("retry after", func(t *testing.T) {
+		rec := do(http.MethodPost, "/api/tools/busy_tool", "", `{}`)
+		if got := rec.Header().Get("Retry-After"); got != "2" {
+			t.Fatalf("expected Retry-After 2, got %q", got)
+		}
+	})
+}
@@ -1,44 +1,130 @@
 package server
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"runtime"
+	"runtime/debug"
 	"time"
 
+	"td_go_mcp/internal/metrics"
 	"td_go_mcp/internal/tools"
 )
 
+// Version is reported by the MCP server and the admin endpoint. Release
+// builds override it with -ldflags "-X td_go_mcp/internal/server.Version=x.y.z".
+var Version = "0.2.0"
+
+// StatusProvider reports the live state of the running MCP process
+type StatusProvider interface {
+	Status(ctx context.Context) ServerInfo
+	// Ready runs the readiness checks behind /readyz
+	Ready(ctx context.Context) []Check
+}
+
+// Check is the result of one readiness check
+type Check struct {
+	Component string `json:"component"` // e.g. "registry" or "database:teradw"
+	OK        bool   `json:"ok"`
+	Error     string `json:"error,omitempty"`
+}
+
+// Readiness is the /readyz response body
+type Readiness struct {
+	Status  string   `json:"status"` // ready or not ready
+	Failing []string `json:"failing,omitempty"`
+	Checks  []Check  `json:"checks"`
+}
+
 type ServerInfo struct {
-	Name      string    `json:"name"`
-	Version   string    `json:"version"`
-	Timestamp time.Time `json:"timestamp"`
-	Status    string    `json:"status"`
-	Tools     int       `json:"tools_loaded"`
+	Name          string            `json:"name"`
+	Version       string            `json:"version"`
+	Timestamp     time.Time         `json:"timestamp"`
+	Status        string            `json:"status"`
+	Tools         int               `json:"tools_loaded"`
+	Prompts       int               `json:"prompts_loaded"`
+	StartedAt     time.Time         `json:"started_at"`
+	UptimeSeconds float64           `json:"uptime_seconds"`
+	Build         BuildInfo         `json:"build"`
+	ToolList      []Component       `json:"tools"`
+	PromptList    []Component       `json:"prompts"`
+	LoadErrors    []tools.LoadError `json:"load_errors,omitempty"`
+	Databases     []DatabaseStatus  `json:"databases"`
+}
+
+// Component is a registered tool or prompt
+type Component struct {
+	Name   string `json:"name"`
+	Source string `json:"source"` // YAML file, or "builtin"
 }
 
-func RegisterRoutes(mux *http.ServeMux) {
-	mux.HandleFunc("/", handleRoot)
+// DatabaseStatus describes one configured connection
+type DatabaseStatus struct {
+	Name   string `json:"name"`
+	Driver string `json:"driver"`
+	Status string `json:"status"` // connected, unreachable or not connected
+	Error  string `json:"error,omitempty"`
+}
+
+type BuildInfo struct {
+	GoVersion     string `json:"go_version"`
+	Module        string `json:"module,omitempty"`
+	ModuleVersion string `json:"module_version,omitempty"`
+	Revision      string `json:"revision,omitempty"`
+	CommitTime    string `json:"commit_time,omitempty"`
+	Modified      bool   `json:"modified,omitempty"`
+}
+
+// ReadBuildInfo returns the Go toolchain and VCS details embedded in the binary
+func ReadBuildInfo() BuildInfo {
+	info := BuildInfo{GoVersion: runtime.Version()}
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+	info.Module = bi.Main.Path
+	info.ModuleVersion = bi.Main.Version
+	for _, s := range bi.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			info.Revision = s.Value
+		case "vcs.time":
+			info.CommitTime = s.Value
+		case "vcs.modified":
+			info.Modified = s.Value == "true"
+		}
+	}
+	return info
+}
+
+// RegisterRoutes mounts the admin endpoints: / reports provider's status,
+// /livez and /healthz report that the process is up, and /readyz returns 503
+// naming the failing components until every readiness check passes, and
+// /metrics exposes Prometheus metrics
+func RegisterRoutes(mux *http.ServeMux, provider StatusProvider) {
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		handleRoot(w, r, provider)
+	})
 	mux.HandleFunc("/healthz", handleHealth)
+	mux.HandleFunc("/livez", handleHealth)
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		handleReady(w, r, provider)
+	})
+	mux.Handle("/metrics", metrics.Handler())
 }
 
-func handleRoot(w http.ResponseWriter, r *http.Request) {
-	// Load tools count
-	loadedTools, err := tools.LoadToolsFromDirectory("tools")
-	toolCount := 0
-	status := "ok"
-	if err != nil {
-		status = "warning: " + err.Error()
-	} else {
-		toolCount = len(loadedTools)
+func handleRoot(w http.ResponseWriter, r *http.Request, provider StatusProvider) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
 	}
 
-	info := ServerInfo{
-		Name:      "td-go-mcp",
-		Version:   "0.2.0",
-		Timestamp: time.Now().UTC(),
-		Status:    status,
-		Tools:     toolCount,
+	info := provider.Status(r.Context())
+	info.Timestamp = time.Now().UTC()
+	if !info.StartedAt.IsZero() {
+		info.UptimeSeconds = time.Since(info.StartedAt).Seconds()
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -47,6 +133,24 @@ func handleRoot(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func handleReady(w http.ResponseWriter, r *http.Request, provider StatusProvider) {
+	result := Readiness{Status: "ready", Checks: provider.Ready(r.Context())}
+	for _, c := range result.Checks {
+		if !c.OK {
+			result.Failing = append(result.Failing, c.Component)
+		}
+	}
+	code := http.StatusOK
+	if len(result.Failing) > 0 {
+		result.Status = "not ready"
+		code = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(result)
+}
+
 func handleHealth(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "text/plain")
 	w.WriteHeader(http.StatusOK)
@@ -1,15 +1,40 @@
 package server
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
+
+	"td_go_mcp/internal/tools"
 )
 
+type fakeProvider struct {
+	checks []Check
+}
+
+func (fakeProvider) Status(ctx context.Context) ServerInfo {
+	return ServerInfo{
+		Name:       "td-go-mcp",
+		Version:    Version,
+		Status:     "degraded",
+		Tools:      1,
+		StartedAt:  time.Now().Add(-time.Minute),
+		ToolList:   []Component{{Name: "count_records", Source: "tools/count.yaml"}},
+		LoadErrors: []tools.LoadError{{File: "tools/bad.yaml", Error: "yaml: line 1"}},
+		Databases:  []DatabaseStatus{{Name: "default", Driver: "teradata", Status: "not connected"}},
+	}
+}
+
+func (p fakeProvider) Ready(ctx context.Context) []Check {
+	return p.checks
+}
+
 func TestRegisterRoutes(t *testing.T) {
 	mux := http.NewServeMux()
-	RegisterRoutes(mux)
+	RegisterRoutes(mux, fakeProvider{})
 
 	t.Run("healthz", func(t *testing.T) {
 		req := httptest.NewRequest(http.MethodGet, "/healthz", nil)
@@ -44,5 +69,66 @@ func TestRegisterRoutes(t *testing.T) {
 		if info.Version != "0.2.0" {
 			t.Fatalf("expected version '0.2.0', got %q", info.Version)
 		}
+		if info.UptimeSeconds < 60 {
+			t.Fatalf("expected uptime of at least 60s, got %v", info.UptimeSeconds)
+		}
+		if len(info.LoadErrors) != 1 || info.LoadErrors[0].File != "tools/bad.yaml" {
+			t.Fatalf("expected load error for tools/bad.yaml, got %+v", info.LoadErrors)
+		}
+		if len(info.Databases) != 1 || info.Databases[0].Status != "not connected" {
+			t.Fatalf("expected one disconnected database, got %+v", info.Databases)
+		}
+	})
+
+	t.Run("unknown path", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/nope", nil)
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusNotFound {
+			t.Fatalf("expected 404, got %d", rec.Code)
+		}
+	})
+}
+
+func TestReadyz(t *testing.T) {
+	t.Run("ready", func(t *testing.T) {
+		mux := http.NewServeMux()
+		RegisterRoutes(mux, fakeProvider{checks: []Check{{Component: "registry", OK: true}, {Component: "database:teradw", OK: true}}})
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+	})
+
+	t.Run("database down", func(t *testing.T) {
+		mux := http.NewServeMux()
+		RegisterRoutes(mux, fakeProvider{checks: []Check{{Component: "registry", OK: true}, {Component: "database:teradw", Error: "ping timed out"}}})
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+		if rec.Code != http.StatusServiceUnavailable {
+			t.Fatalf("expected 503, got %d", rec.Code)
+		}
+		var ready Readiness
+		if err := json.Unmarshal(rec.Body.Bytes(), &ready); err != nil {
+			t.Fatalf("failed to unmarshal response: %v", err)
+		}
+		if len(ready.Failing) != 1 || ready.Failing[0] != "database:teradw" {
+			t.Fatalf("expected database:teradw to be failing, got %+v", ready.Failing)
+		}
+	})
+
+	t.Run("livez", func(t *testing.T) {
+		mux := http.NewServeMux()
+		RegisterRoutes(mux, fakeProvider{checks: []Check{{Component: "registry"}}})
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/livez", nil))
+
+		if rec.Code != http.StatusOK || rec.Body.String() != "ok" {
+			t.Fatalf("expected 200 ok, got %d %q", rec.Code, rec.Body.String())
+		}
 	})
 }
@@ -0,0 +1,168 @@
+package sqlguard
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// ErrNotReadOnly is returned when a statement could modify data or schema
+var ErrNotReadOnly = errors.New("statement is not read-only")
+
+// readOnlyLeaders are the statement keywords accepted as read-only
+var readOnlyLeaders = map[string]bool{
+	"SELECT": true,
+	"SEL":    true,
+	"WITH":   true,
+}
+
+// forbiddenKeywords may not appear anywhere in a read-only statement.
+// INS, UPD and DEL are Teradata abbreviations; SELECT AND CONSUME deletes
+// the rows it reads from a queue table.
+var forbiddenKeywords = map[string]bool{
+	"INSERT": true, "UPDATE": true, "DELETE": true, "MERGE": true, "UPSERT": true,
+	"INS": true, "UPD": true, "DEL": true, "CONSUME": true,
+	"CREATE": true, "ALTER": true, "DROP": true, "RENAME": true,
+	"GRANT": true, "REVOKE": true,
+	"CALL": true, "EXEC": true, "EXECUTE": true, "COLLECT": true,
+	"COMMIT": true, "ROLLBACK": true, "ABORT": true,
+}
+
+// CheckReadOnly returns an error wrapping ErrNotReadOnly unless sql is a
+// single SELECT (or WITH ... SELECT) statement. A leading Teradata
+// "LOCKING ... FOR ACCESS" modifier is allowed.
+func CheckReadOnly(sql string) error {
+	tokens, err := Tokenize(sql)
+	if err != nil {
+		return err
+	}
+
+	// Allow a single trailing semicolon, but nothing after it
+	for i, tok := range tokens {
+		if tok == ";" && i != len(tokens)-1 {
+			return fmt.Errorf("%w: multiple statements are not allowed", ErrNotReadOnly)
+		}
+	}
+	if n := len(tokens); n > 0 && tokens[n-1] == ";" {
+		tokens = tokens[:n-1]
+	}
+	if len(tokens) == 0 {
+		return fmt.Errorf("%w: empty statement", ErrNotReadOnly)
+	}
+
+	tokens, err = skipLockingModifier(tokens)
+	if err != nil {
+		return err
+	}
+	if len(tokens) == 0 || !readOnlyLeaders[tokens[0]] {
+		leader := ""
+		if len(tokens) > 0 {
+			leader = tokens[0]
+		}
+		return fmt.Errorf("%w: statements starting with %q are not allowed", ErrNotReadOnly, leader)
+	}
+
+	for _, tok := range tokens {
+		if forbiddenKeywords[tok] {
+			return fmt.Errorf("%w: keyword %s is not allowed", ErrNotReadOnly, tok)
+		}
+	}
+	return nil
+}
+
+// skipLockingModifier strips "LOCKING|LOCK <object> [name] FOR ACCESS" prefixes.
+// Any lock mode other than ACCESS is rejected since it implies a write intent.
+func skipLockingModifier(tokens []string) ([]string, error) {
+	for len(tokens) > 0 && (tokens[0] == "LOCKING" || tokens[0] == "LOCK") {
+		forIdx := -1
+		for i, tok := range tokens {
+			if tok == "FOR" {
+				forIdx = i
+				break
+			}
+		}
+		if forIdx < 0 || forIdx+1 >= len(tokens) {
+			return nil, fmt.Errorf("%w: incomplete LOCKING modifier", ErrNotReadOnly)
+		}
+		if tokens[forIdx+1] != "ACCESS" {
+			return nil, fmt.Errorf("%w: LOCKING FOR %s is not allowed", ErrNotReadOnly, tokens[forIdx+1])
+		}
+		tokens = tokens[forIdx+2:]
+		// Optional NOWAIT after the lock mode
+		if len(tokens) > 0 && tokens[0] == "NOWAIT" {
+			tokens = tokens[1:]
+		}
+	}
+	return tokens, nil
+}
+
+// Tokenize splits sql into upper-cased words and single-character punctuation.
+// Comments are dropped, string literals become the token "'" and quoted
+// identifiers keep their original case wrapped in double quotes.
+func Tokenize(sql string) ([]string, error) {
+	var tokens []string
+	runes := []rune(sql)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '-' && i+1 < len(runes) && runes[i+1] == '-':
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+		case r == '/' && i+1 < len(runes) && runes[i+1] == '*':
+			j := i + 2
+			for j+1 < len(runes) && !(runes[j] == '*' && runes[j+1] == '/') {
+				j++
+			}
+			if j+1 >= len(runes) {
+				return nil, fmt.Errorf("unterminated block comment")
+			}
+			i = j + 2
+		case r == '\'':
+			j := i + 1
+			for {
+				if j >= len(runes) {
+					return nil, fmt.Errorf("unterminated string literal")
+				}
+				if runes[j] == '\'' {
+					if j+1 < len(runes) && runes[j+1] == '\'' {
+						j += 2
+						continue
+					}
+					break
+				}
+				j++
+			}
+			tokens = append(tokens, "'")
+			i = j + 1
+		case r == '"':
+			j := i + 1
+			for j < len(runes) && runes[j] != '"' {
+				j++
+			}
+			if j >= len(runes) {
+				return nil, fmt.Errorf("unterminated quoted identifier")
+			}
+			tokens = append(tokens, string(runes[i:j+1]))
+			i = j + 1
+		case isWordRune(r):
+			j := i
+			for j < len(runes) && isWordRune(runes[j]) {
+				j++
+			}
+			tokens = append(tokens, strings.ToUpper(string(runes[i:j])))
+			i = j
+		default:
+			tokens = append(tokens, string(r))
+			i++
+		}
+	}
+	return tokens, nil
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || r == '$' || r == '#' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
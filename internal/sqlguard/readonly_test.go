@@ -0,0 +1,100 @@
+package sqlguard
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCheckReadOnly(t *testing.T) {
+	allowed := []string{
+		"SELECT * FROM users",
+		"sel user_id FROM users;",
+		"WITH t AS (SELECT 1 AS x) SELECT x FROM t",
+		"LOCKING ROW FOR ACCESS SELECT * FROM sales",
+		"SELECT 'DROP TABLE users' AS msg -- DELETE in a comment\nFROM dual",
+		"/* UPDATE */ SELECT \"Update\" FROM audit",
+	}
+	for _, sql := range allowed {
+		if err := CheckReadOnly(sql); err != nil {
+			t.Errorf("expected %q to be read-only, got %v", sql, err)
+		}
+	}
+
+	rejected := []string{
+		"",
+		"DELETE FROM users",
+		"SELECT 1; DROP TABLE users",
+		"LOCKING TABLE users FOR WRITE SELECT * FROM users",
+		"CREATE TABLE t AS (SELECT * FROM users) WITH DATA",
+		"WITH t AS (SELECT 1 AS x) INSERT INTO y SELECT x FROM t",
+		"SELECT 'unterminated FROM users",
+		"SELECT AND CONSUME TOP 1 * FROM queue_tbl",
+		"sel and consume top 1 * from queue_tbl;",
+		"DEL FROM users ALL",
+		"WITH t AS (SELECT 1 AS x) INS INTO y SELECT x FROM t",
+		"WITH t AS (SELECT 1 AS x) INS y (x) SELECT x FROM t",
+		"LOCKING ROW FOR ACCESS UPD users SET name = 'x'",
+		"WITH t AS (SELECT 1 AS x) SELECT x FROM t; DEL FROM t",
+	}
+	for _, sql := range rejected {
+		err := CheckReadOnly(sql)
+		if err == nil {
+			t.Errorf("expected %q to be rejected", sql)
+			continue
+		}
+		if sql != "SELECT 'unterminated FROM users" && !errors.Is(err, ErrNotReadOnly) {
+			t.Errorf("expected ErrNotReadOnly for %q, got %v", sql, err)
+		}
+	}
+}
+
+func TestTableRefs(t *testing.T) {
+	sql := `WITH recent AS (SELECT * FROM sales.orders WHERE order_date > CURRENT_DATE - 7)
+SELECT c.name, EXTRACT(YEAR FROM r.order_date) AS yr, a, b AS recent2
+FROM recent r
+JOIN sales.customers c ON c.id = r.customer_id
+LEFT JOIN "Finance"."Rates" fx ON fx.ccy = r.ccy, lookup l
+WHERE r.id IN (SELECT id FROM staging.ids)`
+
+	refs, err := TableRefs(sql)
+	if err != nil {
+		t.Fatalf("TableRefs failed: %v", err)
+	}
+	got := make([]string, len(refs))
+	for i, ref := range refs {
+		got[i] = ref.String()
+	}
+	want := []string{"SALES.ORDERS", "SALES.CUSTOMERS", "Finance.Rates", "STAGING.IDS"}
+	if len(got) != len(want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, got)
+		}
+	}
+
+	refs, _ = TableRefs("SELECT a, b FROM users u, sales.orders o WHERE u.id = o.user_id")
+	if len(refs) != 2 || refs[0].String() != "USERS" || refs[1].String() != "SALES.ORDERS" {
+		t.Fatalf("unexpected comma-join refs: %v", refs)
+	}
+}
+
+func TestAllowlist(t *testing.T) {
+	allow := Allowlist{Databases: []string{"sales"}, Tables: []string{"finance.rates", "lookup"}}
+
+	ok := []TableRef{{Database: "SALES", Table: "ORDERS"}, {Database: "FINANCE", Table: "RATES"}, {Table: "LOOKUP"}}
+	if err := allow.Check(ok); err != nil {
+		t.Fatalf("expected refs to be allowed: %v", err)
+	}
+
+	for _, ref := range []TableRef{{Database: "HR", Table: "SALARIES"}, {Database: "FINANCE", Table: "LEDGER"}, {Table: "ORDERS"}} {
+		if err := allow.Check([]TableRef{ref}); !errors.Is(err, ErrNotAllowed) {
+			t.Errorf("expected %s to be rejected, got %v", ref, err)
+		}
+	}
+
+	if err := (Allowlist{}).Check([]TableRef{{Database: "HR", Table: "SALARIES"}}); err != nil {
+		t.Fatalf("empty allowlist should allow everything: %v", err)
+	}
+}
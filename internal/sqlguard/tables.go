@@ -0,0 +1,230 @@
+package sqlguard
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrNotAllowed is returned when a statement references a database or table
+// outside the configured allowlists
+var ErrNotAllowed = errors.New("object is not allowed")
+
+// TableRef is a table or view referenced in a FROM or JOIN clause
+type TableRef struct {
+	Database string `json:"database,omitempty"`
+	Table    string `json:"table"`
+}
+
+func (t TableRef) String() string {
+	if t.Database == "" {
+		return t.Table
+	}
+	return t.Database + "." + t.Table
+}
+
+// clauseEnd are keywords that end a FROM list
+var clauseEnd = map[string]bool{
+	"WHERE": true, "GROUP": true, "HAVING": true, "QUALIFY": true, "ORDER": true,
+	"UNION": true, "EXCEPT": true, "MINUS": true, "INTERSECT": true, "SAMPLE": true,
+	"ON": true, "USING": true, "JOIN": true, "INNER": true, "LEFT": true, "RIGHT": true,
+	"FULL": true, "CROSS": true, "OUTER": true, "WITH": true, "SELECT": true, "SEL": true,
+}
+
+// fromFunctions use FROM inside their argument list
+var fromFunctions = map[string]bool{
+	"EXTRACT": true, "TRIM": true, "SUBSTRING": true, "POSITION": true, "OVERLAY": true,
+}
+
+// TableRefs returns the tables referenced by sql, excluding common table
+// expression names. Quoted identifiers are returned without their quotes.
+func TableRefs(sql string) ([]TableRef, error) {
+	tokens, err := Tokenize(sql)
+	if err != nil {
+		return nil, err
+	}
+
+	cteNames := commonTableExpressions(tokens)
+
+	var refs []TableRef
+	seen := make(map[string]bool)
+	add := func(ref TableRef) {
+		if ref.Database == "" && cteNames[ref.Table] {
+			return
+		}
+		if !seen[ref.String()] {
+			seen[ref.String()] = true
+			refs = append(refs, ref)
+		}
+	}
+
+	var parens []string
+	for i := 0; i < len(tokens); i++ {
+		switch tokens[i] {
+		case "(":
+			fn := ""
+			if i > 0 {
+				fn = tokens[i-1]
+			}
+			parens = append(parens, fn)
+			continue
+		case ")":
+			if len(parens) > 0 {
+				parens = parens[:len(parens)-1]
+			}
+			continue
+		case "FROM", "JOIN":
+		default:
+			continue
+		}
+		// EXTRACT(YEAR FROM d), TRIM(x FROM s) and friends are not table references
+		if len(parens) > 0 && fromFunctions[parens[len(parens)-1]] {
+			continue
+		}
+		j := i + 1
+		for j < len(tokens) {
+			if tokens[j] == "(" || !isIdentifier(tokens[j]) {
+				break
+			}
+			ref := TableRef{Table: identifierName(tokens[j])}
+			j++
+			if j+1 < len(tokens) && tokens[j] == "." && isIdentifier(tokens[j+1]) {
+				ref = TableRef{Database: ref.Table, Table: identifierName(tokens[j+1])}
+				j += 2
+			}
+			add(ref)
+
+			// Optional alias
+			if j < len(tokens) && tokens[j] == "AS" {
+				j++
+			}
+			if j < len(tokens) && isIdentifier(tokens[j]) && !clauseEnd[tokens[j]] {
+				j++
+			}
+			// Only FROM supports comma-separated table lists
+			if tokens[i] != "FROM" || j >= len(tokens) || tokens[j] != "," {
+				break
+			}
+			j++
+		}
+	}
+	return refs, nil
+}
+
+// commonTableExpressions returns the names defined by a leading
+// WITH [RECURSIVE] name [(cols)] AS (...) [, ...] clause
+func commonTableExpressions(tokens []string) map[string]bool {
+	names := make(map[string]bool)
+	i := 0
+	for i < len(tokens) && tokens[i] != "WITH" {
+		if tokens[i] == "SELECT" || tokens[i] == "SEL" {
+			return names
+		}
+		i++
+	}
+	i++
+	if i < len(tokens) && tokens[i] == "RECURSIVE" {
+		i++
+	}
+	for i < len(tokens) && isIdentifier(tokens[i]) {
+		names[identifierName(tokens[i])] = true
+		i++
+		if i < len(tokens) && tokens[i] == "(" {
+			i = skipParens(tokens, i)
+		}
+		if i >= len(tokens) || tokens[i] != "AS" {
+			break
+		}
+		i++
+		if i >= len(tokens) || tokens[i] != "(" {
+			break
+		}
+		i = skipParens(tokens, i)
+		if i >= len(tokens) || tokens[i] != "," {
+			break
+		}
+		i++
+	}
+	return names
+}
+
+// skipParens returns the index after the parenthesis group opening at tokens[i]
+func skipParens(tokens []string, i int) int {
+	depth := 0
+	for ; i < len(tokens); i++ {
+		switch tokens[i] {
+		case "(":
+			depth++
+		case ")":
+			depth--
+			if depth == 0 {
+				return i + 1
+			}
+		}
+	}
+	return i
+}
+
+// Allowlist restricts which databases and tables a statement may reference.
+// Entries are matched case-insensitively; a table entry may be "db.table",
+// "db.*" or an unqualified table name. Empty lists allow everything.
+type Allowlist struct {
+	Databases []string
+	Tables    []string
+}
+
+// Check returns an error wrapping ErrNotAllowed for the first reference in
+// refs that the allowlist does not permit
+func (a Allowlist) Check(refs []TableRef) error {
+	if len(a.Databases) == 0 && len(a.Tables) == 0 {
+		return nil
+	}
+	for _, ref := range refs {
+		if !a.allows(ref) {
+			if ref.Database == "" {
+				return fmt.Errorf("%w: table %s (qualify it with an allowed database)", ErrNotAllowed, ref)
+			}
+			return fmt.Errorf("%w: %s", ErrNotAllowed, ref)
+		}
+	}
+	return nil
+}
+
+func (a Allowlist) allows(ref TableRef) bool {
+	for _, entry := range a.Tables {
+		db, table, qualified := strings.Cut(entry, ".")
+		if !qualified {
+			if ref.Database == "" && strings.EqualFold(db, ref.Table) {
+				return true
+			}
+			continue
+		}
+		if strings.EqualFold(db, ref.Database) && (table == "*" || strings.EqualFold(table, ref.Table)) {
+			return true
+		}
+	}
+	for _, db := range a.Databases {
+		if ref.Database != "" && strings.EqualFold(db, ref.Database) {
+			return true
+		}
+	}
+	return false
+}
+
+func isIdentifier(tok string) bool {
+	if tok == "" {
+		return false
+	}
+	if tok[0] == '"' {
+		return true
+	}
+	return isWordRune(rune(tok[0])) && !(tok[0] >= '0' && tok[0] <= '9')
+}
+
+// identifierName strips the quotes from quoted identifier tokens
+func identifierName(tok string) string {
+	if strings.HasPrefix(tok, "\"") {
+		return strings.Trim(tok, "\"")
+	}
+	return tok
+}
@@ -5,8 +5,13 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"text/template"
+	"time"
 
 	"gopkg.in/yaml.v3"
+
+	"td_go_mcp/internal/ratelimit"
+	"td_go_mcp/internal/transform"
 )
 
 // ToolDefinition represents a tool loaded from YAML
@@ -15,9 +20,61 @@ type ToolDefinition struct {
 	Description       string               `yaml:"description" json:"description"`
 	Parameters        map[string]Parameter `yaml:"parameters" json:"parameters"`
 	ReturnType        string               `yaml:"return_type" json:"return_type"`
+	Columns           []Column             `yaml:"columns,omitempty" json:"columns,omitempty"`
+	Category          string               `yaml:"category,omitempty" json:"category,omitempty"`
 	SQLTemplate       string               `yaml:"sql_template" json:"sql_template"`
 	Required          []string             `yaml:"required" json:"required"`
 	ReturnTestMessage string               `yaml:"return_test_message,omitempty" json:"return_test_message,omitempty"`
+	AllowedRoles      []string             `yaml:"allowed_roles,omitempty" json:"allowed_roles,omitempty"`
+	AllowedUsers      []string             `yaml:"allowed_users,omitempty" json:"allowed_users,omitempty"`
+	RateLimit         string               `yaml:"rate_limit,omitempty" json:"rate_limit,omitempty"`
+	MaxConcurrency    int                  `yaml:"max_concurrency,omitempty" json:"max_concurrency,omitempty"`
+	CacheTTL          string               `yaml:"cache_ttl,omitempty" json:"cache_ttl,omitempty"`
+	SourceFile        string               `yaml:"-" json:"source_file,omitempty"`
+
+	// Extends names a tool whose definition this one inherits and overrides.
+	// Abstract tools are only bases: they are validated but never registered.
+	Extends  string `yaml:"extends,omitempty" json:"extends,omitempty"`
+	Abstract bool   `yaml:"abstract,omitempty" json:"abstract,omitempty"`
+
+	// Statements replace sql_template with named statements run in order on
+	// one connection, in a transaction when Transaction is set
+	Statements  []Statement `yaml:"statements,omitempty" json:"statements,omitempty"`
+	Transaction bool        `yaml:"transaction,omitempty" json:"transaction,omitempty"`
+
+	// ForEach makes this definition a family of tools, and Fixed holds the
+	// parameter values an expansion pins for its template
+	ForEach *ForEach       `yaml:"for_each,omitempty" json:"for_each,omitempty"`
+	Fixed   map[string]any `yaml:"fixed,omitempty" json:"fixed,omitempty"`
+
+	// Partials are {{define}} bodies by name, private to this tool
+	Partials map[string]string `yaml:"partials,omitempty" json:"partials,omitempty"`
+
+	// Transform reshapes the result rows before they are returned; see
+	// package transform for the operations
+	Transform []map[string]any `yaml:"transform,omitempty" json:"transform,omitempty"`
+
+	// tmpl is sql_template compiled by the loader (stmts the statements),
+	// and partialsUsed the partials they call
+	tmpl         *template.Template
+	stmts        []*template.Template
+	partialsUsed []string
+	transform    *transform.Transform
+	// chain is the resolved extends chain, nearest base first
+	chain []string
+}
+
+// Statement is one named SQL template of a multi-statement tool
+type Statement struct {
+	Name string `yaml:"name" json:"name"`
+	SQL  string `yaml:"sql" json:"sql"`
+}
+
+// Column describes one output column of a tool's result rows
+type Column struct {
+	Name        string `yaml:"name" json:"name"`
+	Type        string `yaml:"type" json:"type"`
+	Description string `yaml:"description,omitempty" json:"description,omitempty"`
 }
 
 // PromptDefinition represents a prompt loaded from YAML
@@ -27,6 +84,13 @@ type PromptDefinition struct {
 	Prompt      string               `yaml:"prompt" json:"prompt"`
 	Description string               `yaml:"description,omitempty" json:"description,omitempty"`
 	Parameters  map[string]Parameter `yaml:"parameters,omitempty" json:"parameters,omitempty"`
+	SourceFile  string               `yaml:"-" json:"source_file,omitempty"`
+}
+
+// LoadError records a YAML file that could not be loaded
+type LoadError struct {
+	File  string `json:"file"`
+	Error string `json:"error"`
 }
 
 // Parameter defines input parameter schema
@@ -34,41 +98,133 @@ type Parameter struct {
 	Type        string `yaml:"type" json:"type"`
 	Description string `yaml:"description" json:"description"`
 	Default     any    `yaml:"default,omitempty" json:"default,omitempty"`
+	Secret      bool   `yaml:"secret,omitempty" json:"secret,omitempty"`
+}
+
+// Limit returns the tool's rate_limit and max_concurrency
+func (td *ToolDefinition) Limit() ratelimit.Limit {
+	return ratelimit.Limit{Rate: td.RateLimit, MaxConcurrency: td.MaxConcurrency}
+}
+
+// CacheDuration returns how long results may be cached; 0 means never.
+// cache_ttl is validated when the tool is loaded.
+func (td *ToolDefinition) CacheDuration() time.Duration {
+	ttl, _ := time.ParseDuration(td.CacheTTL)
+	return ttl
+}
+
+// Chain returns the tools this one inherits from, nearest base first
+func (td *ToolDefinition) Chain() []string {
+	return td.chain
+}
+
+// HasTransform reports whether the tool reshapes its rows
+func (td *ToolDefinition) HasTransform() bool {
+	return td.transform != nil
+}
+
+// ApplyTransform runs the tool's transform on rows. Without one it returns
+// rows unchanged.
+func (td *ToolDefinition) ApplyTransform(rows []map[string]any) (any, error) {
+	out, err := td.transform.Apply(rows)
+	if err != nil {
+		return nil, fmt.Errorf("transform: %w", err)
+	}
+	return out, nil
+}
+
+// SecretParameters returns the names of parameters marked secret
+func (td *ToolDefinition) SecretParameters() []string {
+	var secrets []string
+	for name, param := range td.Parameters {
+		if param.Secret {
+			secrets = append(secrets, name)
+		}
+	}
+	return secrets
 }
 
 // LoadToolsFromDirectory loads all YAML files from tools/ directory
 func LoadToolsFromDirectory(dir string) ([]ToolDefinition, error) {
+	tools, loadErrors, err := LoadTools(dir)
+	if err != nil {
+		return tools, err
+	}
+	if len(loadErrors) > 0 {
+		return tools, fmt.Errorf("error loading %s: %s", loadErrors[0].File, loadErrors[0].Error)
+	}
+	return tools, nil
+}
+
+// LoadTools loads every valid tool in dir. Unlike LoadToolsFromDirectory it
+// does not stop at the first bad file; failures are returned as LoadErrors.
+// The error is only set when dir itself cannot be walked.
+//
+// Files are read first and compiled once every extends chain is resolved,
+// so a tool may extend one defined in any file. Abstract tools are compiled
+// to surface their errors but are not returned. Families with static
+// for_each values are expanded; families with a for_each query are
+// returned as they are for the caller to Expand once a database is up.
+func LoadTools(dir string) ([]ToolDefinition, []LoadError, error) {
 	var tools []ToolDefinition
 
 	if _, err := os.Stat(dir); os.IsNotExist(err) {
-		return tools, nil // No tools directory, return empty
+		return tools, nil, nil // No tools directory, return empty
 	}
+	// Shared partials load first so tool templates can call them
+	lib, loadErrors := LoadLibrary(filepath.Join(dir, PartialsDir))
 
+	var defs []ToolDefinition
 	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
-
-		if !info.IsDir() && (strings.HasSuffix(path, ".yaml") || strings.HasSuffix(path, ".yml")) {
-			// Skip prompt files when loading tools
-			if isPromptFile(path) {
-				return nil
-			}
-
-			tool, err := loadToolFromFile(path)
-			if err != nil {
-				return fmt.Errorf("error loading %s: %w", path, err)
-			}
-			tools = append(tools, tool)
+		if info.IsDir() && path != dir && strings.HasPrefix(info.Name(), "_") {
+			return filepath.SkipDir // _partials and other support directories
+		}
+		if info.IsDir() || !(strings.HasSuffix(path, ".yaml") || strings.HasSuffix(path, ".yml")) || !isToolFile(path) {
+			return nil
+		}
+		tool, err := readToolFile(path)
+		if err != nil {
+			loadErrors = append(loadErrors, LoadError{File: path, Error: err.Error()})
+			return nil
 		}
+		defs = append(defs, tool)
 		return nil
 	})
+	if err != nil {
+		return tools, loadErrors, err
+	}
 
-	return tools, err
+	defs, resolveErrors := resolveExtends(defs)
+	loadErrors = append(loadErrors, resolveErrors...)
+	for _, tool := range defs {
+		if err := tool.compile(lib); err != nil {
+			loadErrors = append(loadErrors, LoadError{File: tool.SourceFile, Error: err.Error()})
+			continue
+		}
+		if tool.Abstract {
+			continue
+		}
+		if tool.ForEach != nil && tool.ForEach.Query == "" {
+			expanded, err := Expand(tool, tool.ForEach.Items())
+			if err != nil {
+				loadErrors = append(loadErrors, LoadError{File: tool.SourceFile, Error: err.Error()})
+				continue
+			}
+			tools = append(tools, expanded...)
+			continue
+		}
+		tools = append(tools, tool)
+	}
+	return tools, loadErrors, nil
 }
 
-func loadToolFromFile(filepath string) (ToolDefinition, error) {
-	var tool ToolDefinition
+// readToolFile parses one tool file without validating its template, which
+// may come from the tool it extends
+func readToolFile(filepath string) (ToolDefinition, error) {
+	tool := ToolDefinition{SourceFile: filepath}
 
 	data, err := os.ReadFile(filepath)
 	if err != nil {
@@ -80,17 +236,58 @@ func loadToolFromFile(filepath string) (ToolDefinition, error) {
 		return tool, err
 	}
 
-	// Validate required fields
 	if tool.Name == "" {
 		return tool, fmt.Errorf("tool name is required")
 	}
-	if tool.SQLTemplate == "" {
-		return tool, fmt.Errorf("sql_template is required")
-	}
-
 	return tool, nil
 }
 
+// compile validates a resolved tool and parses its template
+func (td *ToolDefinition) compile(lib *Library) error {
+	var used []string
+	var err error
+	switch {
+	case td.SQLTemplate != "" && len(td.Statements) > 0:
+		return fmt.Errorf("use either sql_template or statements, not both")
+	case len(td.Statements) > 0:
+		if td.CacheTTL != "" {
+			return fmt.Errorf("cache_ttl is not supported with statements")
+		}
+		if td.stmts, used, err = parseStatements(*td, lib); err != nil {
+			return err
+		}
+	case td.SQLTemplate != "":
+		if td.tmpl, used, err = parseTemplate(*td, lib); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("sql_template is required")
+	}
+	for _, name := range used {
+		if source := lib.Source(name); source != "" && td.Partials[name] == "" {
+			name += " (" + source + ")"
+		}
+		td.partialsUsed = append(td.partialsUsed, name)
+	}
+	if err := td.Limit().Validate(); err != nil {
+		return err
+	}
+	if td.ForEach != nil {
+		if err := td.ForEach.validate(); err != nil {
+			return err
+		}
+	}
+	if td.CacheTTL != "" {
+		if ttl, err := time.ParseDuration(td.CacheTTL); err != nil || ttl < 0 {
+			return fmt.Errorf("invalid cache_ttl %q (use a duration such as 30s or 5m)", td.CacheTTL)
+		}
+	}
+	if td.transform, err = transform.Parse(td.Transform, td.ReturnType); err != nil {
+		return err
+	}
+	return nil
+}
+
 // ToMCPTool converts ToolDefinition to MCP Tool format
 func (td *ToolDefinition) ToMCPTool() map[string]any {
 	properties := make(map[string]any)
@@ -117,53 +314,83 @@ func (td *ToolDefinition) ToMCPTool() map[string]any {
 
 // LoadPromptsFromDirectory loads all prompt YAML files from tools/ directory
 func LoadPromptsFromDirectory(dir string) ([]PromptDefinition, error) {
+	prompts, loadErrors, err := LoadPrompts(dir)
+	if err != nil {
+		return prompts, err
+	}
+	if len(loadErrors) > 0 {
+		return prompts, fmt.Errorf("error loading prompt %s: %s", loadErrors[0].File, loadErrors[0].Error)
+	}
+	return prompts, nil
+}
+
+// LoadPrompts loads every valid prompt in dir, collecting per-file failures
+// as LoadErrors instead of stopping at the first one
+func LoadPrompts(dir string) ([]PromptDefinition, []LoadError, error) {
 	var prompts []PromptDefinition
+	var loadErrors []LoadError
 
 	if _, err := os.Stat(dir); os.IsNotExist(err) {
-		return prompts, nil // No tools directory, return empty
+		return prompts, nil, nil // No tools directory, return empty
 	}
 
 	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
-
-		if !info.IsDir() && (strings.HasSuffix(path, ".yaml") || strings.HasSuffix(path, ".yml")) {
-			// First check if this is a prompt file
-			if isPromptFile(path) {
-				prompt, err := loadPromptFromFile(path)
-				if err != nil {
-					return fmt.Errorf("error loading prompt %s: %w", path, err)
-				}
-				prompts = append(prompts, prompt)
-			}
+		if info.IsDir() && path != dir && strings.HasPrefix(info.Name(), "_") {
+			return filepath.SkipDir // _partials and other support directories
+		}
+		if info.IsDir() || !(strings.HasSuffix(path, ".yaml") || strings.HasSuffix(path, ".yml")) || !isPromptFile(path) {
+			return nil
 		}
+		prompt, err := loadPromptFromFile(path)
+		if err != nil {
+			loadErrors = append(loadErrors, LoadError{File: path, Error: err.Error()})
+			return nil
+		}
+		prompts = append(prompts, prompt)
 		return nil
 	})
 
-	return prompts, err
+	return prompts, loadErrors, err
 }
 
 func isPromptFile(filepath string) bool {
+	return yamlType(filepath) == "prompt"
+}
+
+// isToolFile reports whether a YAML file holds a tool rather than a prompt
+// or pipeline
+func isToolFile(filepath string) bool {
+	switch yamlType(filepath) {
+	case "prompt", PipelineType:
+		return false
+	}
+	return true
+}
+
+// yamlType returns the type: field of a YAML file, "" for tools
+func yamlType(filepath string) string {
 	data, err := os.ReadFile(filepath)
 	if err != nil {
-		return false
+		return ""
 	}
 
-	// Quick check if this is a prompt file by looking for type: prompt
+	// Quick check of the file's kind by looking for type:
 	var quickCheck struct {
 		Type string `yaml:"type"`
 	}
 
 	if err := yaml.Unmarshal(data, &quickCheck); err != nil {
-		return false
+		return ""
 	}
 
-	return quickCheck.Type == "prompt"
+	return quickCheck.Type
 }
 
 func loadPromptFromFile(filepath string) (PromptDefinition, error) {
-	var prompt PromptDefinition
+	prompt := PromptDefinition{SourceFile: filepath}
 
 	data, err := os.ReadFile(filepath)
 	if err != nil {
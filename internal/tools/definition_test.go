@@ -1,6 +1,11 @@
 package tools
 
 import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 )
 
@@ -41,6 +46,35 @@ func TestLoadToolsFromDirectory(t *testing.T) {
 	}
 }
 
+func TestLoadToolsCollectsErrors(t *testing.T) {
+	dir := t.TempDir()
+	good := "name: good_tool\ndescription: ok\nsql_template: SELECT 1\n"
+	if err := os.WriteFile(filepath.Join(dir, "good.yaml"), []byte(good), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "bad.yaml"), []byte("name: [unclosed"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	badLimit := "name: limited\nsql_template: SELECT 1\nrate_limit: 10 per minute\n"
+	if err := os.WriteFile(filepath.Join(dir, "limited.yaml"), []byte(badLimit), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tools, loadErrors, err := LoadTools(dir)
+	if err != nil {
+		t.Fatalf("LoadTools returned error: %v", err)
+	}
+	if len(tools) != 1 || tools[0].Name != "good_tool" {
+		t.Fatalf("expected only good_tool to load, got %+v", tools)
+	}
+	if tools[0].SourceFile != filepath.Join(dir, "good.yaml") {
+		t.Errorf("expected source file good.yaml, got %q", tools[0].SourceFile)
+	}
+	if len(loadErrors) != 2 || loadErrors[0].File != filepath.Join(dir, "bad.yaml") || loadErrors[1].File != filepath.Join(dir, "limited.yaml") {
+		t.Fatalf("expected load errors for bad.yaml and limited.yaml, got %+v", loadErrors)
+	}
+}
+
 func TestSQLProcessor(t *testing.T) {
 	tool := ToolDefinition{
 		Name:        "test_tool",
@@ -52,7 +86,10 @@ func TestSQLProcessor(t *testing.T) {
 		Required: []string{"user_id"},
 	}
 
-	processor := NewSQLProcessor(tool)
+	processor, err := NewSQLProcessor(tool)
+	if err != nil {
+		t.Fatalf("NewSQLProcessor: %v", err)
+	}
 
 	// Test with required parameter
 	params := map[string]any{"user_id": "123"}
@@ -84,3 +121,87 @@ func TestSQLProcessor(t *testing.T) {
 		t.Error("Expected validation error for missing required parameter")
 	}
 }
+
+func TestTemplateErrorsAtLoad(t *testing.T) {
+	if _, err := NewSQLProcessor(ToolDefinition{Name: "broken", SQLTemplate: "SELECT {{if .x}}1"}); err == nil {
+		t.Fatal("expected NewSQLProcessor to reject an unterminated if")
+	}
+
+	dir := t.TempDir()
+	broken := "name: broken\nsql_template: \"SELECT * FROM t WHERE {{#if .x}}a = 1{{/if}}\"\n"
+	if err := os.WriteFile(filepath.Join(dir, "broken.yaml"), []byte(broken), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tools, loadErrors, err := LoadTools(dir)
+	if err != nil {
+		t.Fatalf("LoadTools returned error: %v", err)
+	}
+	if len(tools) != 0 || len(loadErrors) != 1 || !strings.Contains(loadErrors[0].Error, "invalid SQL template") {
+		t.Fatalf("expected a template load error, got tools %+v, errors %+v", tools, loadErrors)
+	}
+}
+
+func TestSQLProcessorConcurrentUse(t *testing.T) {
+	processor, err := NewSQLProcessor(ToolDefinition{
+		Name:        "by_id",
+		SQLTemplate: "SELECT * FROM users WHERE id = '{{escape .id}}'",
+		Parameters:  map[string]Parameter{"id": {Type: "string"}},
+	})
+	if err != nil {
+		t.Fatalf("NewSQLProcessor: %v", err)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			id := fmt.Sprintf("u%d", i)
+			sql, err := processor.ProcessTemplate(map[string]any{"id": id})
+			if err != nil || sql != "SELECT * FROM users WHERE id = '"+id+"'" {
+				t.Errorf("goroutine %d: got %q, %v", i, sql, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestTransformAtLoad(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "count.yaml"), "name: count\nreturn_type: integer\nsql_template: SELECT COUNT(*) AS n FROM t\ntransform:\n  - scalar: n\n")
+	writeFile(t, filepath.Join(dir, "bad.yaml"), "name: bad\nsql_template: SELECT 1\ntransform:\n  - compute: {pct: 'n * '}\n")
+
+	tools, loadErrors, err := LoadTools(dir)
+	if err != nil {
+		t.Fatalf("LoadTools returned error: %v", err)
+	}
+	if len(loadErrors) != 1 || !strings.Contains(loadErrors[0].Error, "transform step 1 (compute)") {
+		t.Fatalf("expected a transform load error, got %+v", loadErrors)
+	}
+	if len(tools) != 1 || !tools[0].HasTransform() {
+		t.Fatalf("expected count to load with a transform, got %+v", tools)
+	}
+	got, err := tools[0].ApplyTransform([]map[string]any{{"n": "1250"}})
+	if err != nil || got != int64(1250) {
+		t.Fatalf("expected 1250, got %v %v", got, err)
+	}
+
+	plain := ToolDefinition{Name: "plain"}
+	rows := []map[string]any{{"n": 1}}
+	if got, err := plain.ApplyTransform(rows); err != nil || len(got.([]map[string]any)) != 1 {
+		t.Fatalf("expected rows unchanged without a transform, got %v %v", got, err)
+	}
+}
+
+func TestLoadPromptsFromDirectory(t *testing.T) {
+	prompts, err := LoadPromptsFromDirectory("../../tools")
+	if err != nil || len(prompts) == 0 {
+		t.Fatalf("expected the repo's prompts to load, got %d, %v", len(prompts), err)
+	}
+
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "bad.yaml"), "type: prompt\nname: bad\n")
+	writeFile(t, filepath.Join(dir, "_partials", "skip.yaml"), "type: prompt\nname: [unclosed\n")
+	if _, err := LoadPromptsFromDirectory(dir); err == nil || !strings.Contains(err.Error(), "bad.yaml") {
+		t.Fatalf("expected the first prompt load error, got %v", err)
+	}
+}
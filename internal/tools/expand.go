@@ -0,0 +1,94 @@
+package tools
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// ForEach expands one definition into a family of tools, one per item.
+// Items come from Values, or from Query run against the database at startup.
+type ForEach struct {
+	// As names the parameter a scalar value is bound to. Map values bind
+	// each key instead.
+	As     string `yaml:"as,omitempty" json:"as,omitempty"`
+	Values []any  `yaml:"values,omitempty" json:"values,omitempty"`
+	// Query returns one row per tool; its columns are named as parameters
+	Query string `yaml:"query,omitempty" json:"query,omitempty"`
+}
+
+// validate checks that exactly one source of items is set
+func (fe *ForEach) validate() error {
+	if (len(fe.Values) > 0) == (fe.Query != "") {
+		return fmt.Errorf("for_each needs either values or query")
+	}
+	for _, v := range fe.Values {
+		if _, ok := v.(map[string]any); !ok && fe.As == "" {
+			return fmt.Errorf("for_each needs as to bind scalar value %v", v)
+		}
+	}
+	return nil
+}
+
+// Items returns the static values as parameter maps
+func (fe *ForEach) Items() []map[string]any {
+	items := make([]map[string]any, 0, len(fe.Values))
+	for _, v := range fe.Values {
+		if m, ok := v.(map[string]any); ok {
+			items = append(items, m)
+		} else {
+			items = append(items, map[string]any{fe.As: v})
+		}
+	}
+	return items
+}
+
+// Expand instantiates a for_each family once per item. name and
+// description are rendered as templates over the item, and the item's
+// values become fixed parameters that callers cannot set.
+func Expand(family ToolDefinition, items []map[string]any) ([]ToolDefinition, error) {
+	nameTmpl, err := template.New("name").Funcs(templateFuncs).Option("missingkey=error").Parse(family.Name)
+	if err != nil {
+		return nil, fmt.Errorf("invalid name template: %w", err)
+	}
+	descTmpl, err := template.New("description").Funcs(templateFuncs).Option("missingkey=error").Parse(family.Description)
+	if err != nil {
+		return nil, fmt.Errorf("invalid description template: %w", err)
+	}
+
+	var tools []ToolDefinition
+	seen := map[string]bool{}
+	for _, item := range items {
+		var name, desc bytes.Buffer
+		if err := nameTmpl.Execute(&name, item); err != nil {
+			return nil, fmt.Errorf("name for %v: %w", item, err)
+		}
+		if err := descTmpl.Execute(&desc, item); err != nil {
+			return nil, fmt.Errorf("description for %v: %w", item, err)
+		}
+		tool := family
+		tool.Name = strings.TrimSpace(name.String())
+		tool.Description = strings.TrimSpace(desc.String())
+		if tool.Name == "" || seen[tool.Name] {
+			return nil, fmt.Errorf("for_each item %v does not give a unique tool name (got %q)", item, tool.Name)
+		}
+		seen[tool.Name] = true
+		tool.ForEach = nil
+		tool.Fixed = mergeMaps(family.Fixed, item)
+		tool.Parameters = map[string]Parameter{}
+		for pname, param := range family.Parameters {
+			if _, ok := tool.Fixed[pname]; !ok {
+				tool.Parameters[pname] = param
+			}
+		}
+		tool.Required = []string{}
+		for _, pname := range family.Required {
+			if _, ok := tool.Fixed[pname]; !ok {
+				tool.Required = append(tool.Required, pname)
+			}
+		}
+		tools = append(tools, tool)
+	}
+	return tools, nil
+}
@@ -0,0 +1,103 @@
+package tools
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadToolsExpandsForEach(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "count.yaml"), `name: count_{{.table}}
+description: Count {{.table}}
+for_each:
+  as: table
+  values: [orders, users]
+parameters:
+  table:
+    type: string
+  day:
+    type: string
+required: [table]
+sql_template: "SELECT COUNT(*) FROM {{.table}}{{if .day}} WHERE d = '{{.day}}'{{end}}"
+`)
+	writeFile(t, filepath.Join(dir, "by_region.yaml"), `name: sales_{{.region}}
+description: Sales in {{.label}}
+for_each:
+  values:
+    - {region: eu, label: Europe}
+    - {region: us, label: United States}
+sql_template: "SELECT * FROM sales WHERE region = '{{.region}}'"
+`)
+
+	tools, loadErrors, err := LoadTools(dir)
+	if err != nil || len(loadErrors) != 0 {
+		t.Fatalf("LoadTools: %v %+v", err, loadErrors)
+	}
+	var names []string
+	byName := map[string]ToolDefinition{}
+	for _, tool := range tools {
+		names = append(names, tool.Name)
+		byName[tool.Name] = tool
+	}
+	if got := strings.Join(names, ","); got != "sales_eu,sales_us,count_orders,count_users" {
+		t.Fatalf("unexpected expansions %s", got)
+	}
+
+	orders := byName["count_orders"]
+	if orders.Description != "Count orders" || orders.ForEach != nil || orders.Fixed["table"] != "orders" {
+		t.Fatalf("unexpected expansion %+v", orders)
+	}
+	if _, ok := orders.Parameters["table"]; ok || len(orders.Required) != 0 || len(orders.Parameters) != 1 {
+		t.Fatalf("expected fixed parameter to leave the schema, got %+v required %v", orders.Parameters, orders.Required)
+	}
+	if byName["sales_us"].Description != "Sales in United States" {
+		t.Errorf("expected description from map item, got %q", byName["sales_us"].Description)
+	}
+
+	processor, err := NewSQLProcessor(orders)
+	if err != nil {
+		t.Fatalf("NewSQLProcessor: %v", err)
+	}
+	sql, err := processor.ProcessTemplate(map[string]any{"table": "secrets", "day": "2024-01-15"})
+	if err != nil {
+		t.Fatalf("ProcessTemplate: %v", err)
+	}
+	if want := "SELECT COUNT(*) FROM orders WHERE d = '2024-01-15'"; sql != want {
+		t.Fatalf("expected fixed value to win, got %q", sql)
+	}
+}
+
+func TestForEachErrors(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "same.yaml"), "name: same\nfor_each: {as: x, values: [a, b]}\nsql_template: SELECT 1\n")
+	writeFile(t, filepath.Join(dir, "both.yaml"), "name: t_{{.x}}\nfor_each: {as: x, values: [a], query: SELECT 1}\nsql_template: SELECT 1\n")
+	writeFile(t, filepath.Join(dir, "unbound.yaml"), "name: u_{{.x}}\nfor_each: {values: [a]}\nsql_template: SELECT 1\n")
+	writeFile(t, filepath.Join(dir, "catalog.yaml"), "name: c_{{.x}}\nfor_each: {query: SELECT x FROM catalog}\nsql_template: SELECT 1\n")
+
+	tools, loadErrors, err := LoadTools(dir)
+	if err != nil {
+		t.Fatalf("LoadTools: %v", err)
+	}
+	if len(tools) != 1 || tools[0].ForEach == nil || tools[0].ForEach.Query == "" {
+		t.Fatalf("expected the query family to be returned unexpanded, got %+v", tools)
+	}
+	got := map[string]string{}
+	for _, e := range loadErrors {
+		got[filepath.Base(e.File)] = e.Error
+	}
+	for file, msg := range map[string]string{
+		"same.yaml":    "does not give a unique tool name",
+		"both.yaml":    "for_each needs either values or query",
+		"unbound.yaml": "for_each needs as",
+	} {
+		if !strings.Contains(got[file], msg) {
+			t.Errorf("%s: expected error containing %q, got %q", file, msg, got[file])
+		}
+	}
+
+	expanded, err := Expand(tools[0], []map[string]any{{"x": "one"}, {"x": "two"}})
+	if err != nil || len(expanded) != 2 || expanded[1].Name != "c_two" {
+		t.Fatalf("expected query rows to expand, got %+v %v", expanded, err)
+	}
+}
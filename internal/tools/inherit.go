@@ -0,0 +1,174 @@
+package tools
+
+import (
+	"fmt"
+	"strings"
+)
+
+// resolveExtends replaces every tool that extends another with its effective
+// definition: the base chain merged top-down, nearest definition winning.
+// Tools with an unknown base, or in an extends cycle, are returned as
+// LoadErrors and left out.
+func resolveExtends(defs []ToolDefinition) ([]ToolDefinition, []LoadError) {
+	byName := make(map[string]int, len(defs))
+	for i, def := range defs {
+		if _, ok := byName[def.Name]; !ok {
+			byName[def.Name] = i
+		}
+	}
+
+	resolved := make(map[int]ToolDefinition, len(defs))
+	failed := make(map[int]error)
+	var resolve func(i int, path []string) (ToolDefinition, error)
+	resolve = func(i int, path []string) (ToolDefinition, error) {
+		if def, ok := resolved[i]; ok {
+			return def, nil
+		}
+		if err, ok := failed[i]; ok {
+			return ToolDefinition{}, err
+		}
+		def := defs[i]
+		for n, name := range path {
+			if name == def.Name {
+				return ToolDefinition{}, &cycleError{path: append(append([]string{}, path[n:]...), def.Name)}
+			}
+		}
+		if def.Extends == "" {
+			resolved[i] = def
+			return def, nil
+		}
+		j, ok := byName[def.Extends]
+		if !ok {
+			err := fmt.Errorf("extends unknown tool %q", def.Extends)
+			failed[i] = err
+			return ToolDefinition{}, err
+		}
+		base, err := resolve(j, append(path, def.Name))
+		if err != nil {
+			// Every tool in a cycle reports the cycle itself
+			if cycle, ok := err.(*cycleError); !ok || !cycle.contains(def.Name) {
+				err = fmt.Errorf("extends %s: %w", def.Extends, err)
+			}
+			failed[i] = err
+			return ToolDefinition{}, err
+		}
+		def = inherit(def, base)
+		resolved[i] = def
+		return def, nil
+	}
+
+	var out []ToolDefinition
+	var loadErrors []LoadError
+	for i := range defs {
+		def, err := resolve(i, nil)
+		if err != nil {
+			loadErrors = append(loadErrors, LoadError{File: defs[i].SourceFile, Error: err.Error()})
+			continue
+		}
+		out = append(out, def)
+	}
+	return out, loadErrors
+}
+
+// cycleError is a chain of extends that leads back to its start
+type cycleError struct {
+	path []string
+}
+
+func (e *cycleError) Error() string {
+	return "extends cycle: " + strings.Join(e.path, " -> ")
+}
+
+func (e *cycleError) contains(name string) bool {
+	for _, n := range e.path {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// inherit merges child over its resolved base. Fields the child sets win;
+// parameters and partials merge by name, so a child can override one
+// parameter or one {{block}} and keep the rest.
+func inherit(child, base ToolDefinition) ToolDefinition {
+	def := base
+	def.Name = child.Name
+	def.Extends = child.Extends
+	def.Abstract = child.Abstract
+	def.SourceFile = child.SourceFile
+	def.chain = append([]string{base.Name}, base.chain...)
+
+	if child.Description != "" {
+		def.Description = child.Description
+	}
+	if child.ReturnType != "" {
+		def.ReturnType = child.ReturnType
+	}
+	if child.Category != "" {
+		def.Category = child.Category
+	}
+	// sql_template and statements replace each other
+	if child.SQLTemplate != "" {
+		def.SQLTemplate = child.SQLTemplate
+		def.Statements = nil
+	}
+	if child.Statements != nil {
+		def.Statements = child.Statements
+		def.SQLTemplate = ""
+	}
+	if child.Transaction {
+		def.Transaction = true
+	}
+	if child.ReturnTestMessage != "" {
+		def.ReturnTestMessage = child.ReturnTestMessage
+	}
+	if child.RateLimit != "" {
+		def.RateLimit = child.RateLimit
+	}
+	if child.CacheTTL != "" {
+		def.CacheTTL = child.CacheTTL
+	}
+	if child.ForEach != nil {
+		def.ForEach = child.ForEach
+	}
+	if child.MaxConcurrency != 0 {
+		def.MaxConcurrency = child.MaxConcurrency
+	}
+	// A list the child sets, even to [], replaces the base's
+	if child.Required != nil {
+		def.Required = child.Required
+	}
+	if child.Columns != nil {
+		def.Columns = child.Columns
+	}
+	if child.AllowedRoles != nil {
+		def.AllowedRoles = child.AllowedRoles
+	}
+	if child.AllowedUsers != nil {
+		def.AllowedUsers = child.AllowedUsers
+	}
+	if child.Transform != nil {
+		def.Transform = child.Transform
+	}
+
+	def.Parameters = mergeMaps(base.Parameters, child.Parameters)
+	def.Partials = mergeMaps(base.Partials, child.Partials)
+	def.Fixed = mergeMaps(base.Fixed, child.Fixed)
+	return def
+}
+
+// mergeMaps copies base and overlays child, leaving both untouched
+func mergeMaps[V any](base, child map[string]V) map[string]V {
+	if base == nil && child == nil {
+		return nil
+	}
+	merged := make(map[string]V, len(base)+len(child))
+	for k, v := range base {
+		merged[k] = v
+	}
+	for k, v := range child {
+		merged[k] = v
+	}
+	return merged
+}
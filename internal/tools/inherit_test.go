@@ -0,0 +1,117 @@
+package tools
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadToolsWithExtends(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "base.yaml"), `name: base_count
+abstract: true
+description: Count rows by status
+parameters:
+  status:
+    type: string
+    description: Status filter
+  limit:
+    type: integer
+    default: 10
+required: [status]
+category: analytics
+cache_ttl: 1m
+sql_template: "SELECT status, COUNT(*) FROM {{block \"table\" .}}t{{end}} WHERE status = '{{.status}}'{{block \"extra\" .}}{{end}} LIMIT {{.limit}}"
+`)
+	writeFile(t, filepath.Join(dir, "orders.yaml"), `name: count_orders
+extends: base_count
+parameters:
+  limit:
+    type: integer
+    default: 5
+partials:
+  table: orders
+`)
+	writeFile(t, filepath.Join(dir, "open_orders.yaml"), `name: count_open_orders
+extends: count_orders
+description: Count open orders
+required: []
+partials:
+  extra: " AND open = 1"
+`)
+
+	tools, loadErrors, err := LoadTools(dir)
+	if err != nil || len(loadErrors) != 0 {
+		t.Fatalf("LoadTools: %v %+v", err, loadErrors)
+	}
+	byName := map[string]ToolDefinition{}
+	for _, tool := range tools {
+		byName[tool.Name] = tool
+	}
+	if _, ok := byName["base_count"]; ok || len(tools) != 2 {
+		t.Fatalf("expected the abstract base to be left out, got %d tools", len(tools))
+	}
+
+	orders := byName["count_orders"]
+	if orders.Description != "Count rows by status" || orders.Category != "analytics" || orders.CacheTTL != "1m" {
+		t.Fatalf("expected settings to be inherited, got %+v", orders)
+	}
+	if len(orders.Required) != 1 || orders.Parameters["status"].Description != "Status filter" || orders.Parameters["limit"].Default != 5 {
+		t.Fatalf("expected parameters merged by name, got %+v required %v", orders.Parameters, orders.Required)
+	}
+	if orders.SourceFile != filepath.Join(dir, "orders.yaml") {
+		t.Errorf("expected the child's source file, got %s", orders.SourceFile)
+	}
+
+	open := byName["count_open_orders"]
+	if chain := strings.Join(open.Chain(), ","); chain != "count_orders,base_count" {
+		t.Fatalf("expected chain count_orders,base_count, got %q", chain)
+	}
+	if open.Description != "Count open orders" || len(open.Required) != 0 {
+		t.Fatalf("expected child overrides, got %q required %v", open.Description, open.Required)
+	}
+	processor, err := NewSQLProcessor(open)
+	if err != nil {
+		t.Fatalf("NewSQLProcessor: %v", err)
+	}
+	sql, err := processor.ProcessTemplate(map[string]any{"status": "new"})
+	if err != nil {
+		t.Fatalf("ProcessTemplate: %v", err)
+	}
+	if want := "SELECT status, COUNT(*) FROM orders WHERE status = 'new' AND open = 1 LIMIT 5"; sql != want {
+		t.Fatalf("expected blocks overridden down the chain:\n%s\ngot:\n%s", want, sql)
+	}
+}
+
+func TestExtendsErrors(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "a.yaml"), "name: a\nextends: b\n")
+	writeFile(t, filepath.Join(dir, "b.yaml"), "name: b\nextends: a\nsql_template: SELECT 1\n")
+	writeFile(t, filepath.Join(dir, "c.yaml"), "name: c\nextends: a\n")
+	writeFile(t, filepath.Join(dir, "d.yaml"), "name: d\nextends: missing\n")
+	writeFile(t, filepath.Join(dir, "e.yaml"), "name: e\nextends: e\nsql_template: SELECT 1\n")
+
+	tools, loadErrors, err := LoadTools(dir)
+	if err != nil {
+		t.Fatalf("LoadTools: %v", err)
+	}
+	if len(tools) != 0 {
+		t.Fatalf("expected no tools to load, got %+v", tools)
+	}
+	got := map[string]string{}
+	for _, e := range loadErrors {
+		got[filepath.Base(e.File)] = e.Error
+	}
+	want := map[string]string{
+		"a.yaml": "extends cycle: a -> b -> a",
+		"b.yaml": "extends cycle: a -> b -> a",
+		"c.yaml": "extends a: extends cycle: a -> b -> a",
+		"d.yaml": `extends unknown tool "missing"`,
+		"e.yaml": "extends cycle: e -> e",
+	}
+	for file, msg := range want {
+		if got[file] != msg {
+			t.Errorf("%s: expected %q, got %q", file, msg, got[file])
+		}
+	}
+}
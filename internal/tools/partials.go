@@ -0,0 +1,248 @@
+package tools
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+// PartialsDir is the subdirectory of the tools directory holding shared
+// {{define}} blocks. Directories starting with "_" are never loaded as tools.
+const PartialsDir = "_partials"
+
+// templateFuncs are available to every SQL template and partial
+var templateFuncs = template.FuncMap{
+	"escape": escapeSQL,
+	"dict":   dict,
+}
+
+// Library is the set of shared partials every tool template can call with
+// {{template "name" .}}
+type Library struct {
+	base    *template.Template
+	sources map[string]string // partial name -> defining file
+}
+
+// LoadLibrary parses every .sql and .tmpl file in dir. Files that fail to
+// parse, or define a partial another file already defined, are returned as
+// LoadErrors and left out. A missing dir is an empty library.
+func LoadLibrary(dir string) (*Library, []LoadError) {
+	lib := &Library{base: template.New(PartialsDir).Funcs(templateFuncs), sources: map[string]string{}}
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return lib, nil
+	}
+	if err != nil {
+		return lib, []LoadError{{File: dir, Error: err.Error()}}
+	}
+
+	var loadErrors []LoadError
+	for _, entry := range entries {
+		if entry.IsDir() || !(strings.HasSuffix(entry.Name(), ".sql") || strings.HasSuffix(entry.Name(), ".tmpl")) {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		if err := lib.add(path); err != nil {
+			loadErrors = append(loadErrors, LoadError{File: path, Error: err.Error()})
+		}
+	}
+	return lib, loadErrors
+}
+
+// add parses one partials file into the library
+func (l *Library) add(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	// Parse alone first to learn the file's partials without touching the library
+	file, err := template.New(path).Funcs(templateFuncs).Parse(string(data))
+	if err != nil {
+		return fmt.Errorf("invalid partial: %w", err)
+	}
+	var names []string
+	for _, t := range file.Templates() {
+		if t.Name() == path {
+			continue
+		}
+		if other, ok := l.sources[t.Name()]; ok {
+			return fmt.Errorf("partial %q is already defined in %s", t.Name(), other)
+		}
+		names = append(names, t.Name())
+	}
+	for _, t := range file.Templates() {
+		if t.Name() != path {
+			if _, err := l.base.AddParseTree(t.Name(), t.Tree); err != nil {
+				return err
+			}
+		}
+	}
+	for _, name := range names {
+		l.sources[name] = path
+	}
+	return nil
+}
+
+// Source returns the file that defines a shared partial, or ""
+func (l *Library) Source(name string) string {
+	if l == nil {
+		return ""
+	}
+	return l.sources[name]
+}
+
+// parseTemplate compiles a tool's sql_template together with the shared
+// partials and the tool's own partials, which override {{block}} defaults,
+// and checks that every {{template}} reference resolves. It returns the
+// partials the tool uses.
+func parseTemplate(tool ToolDefinition, lib *Library) (*template.Template, []string, error) {
+	tmpl, used, err := parseSQL(tool.Name, tool.SQLTemplate, tool.Partials, lib)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid SQL template: %w", err)
+	}
+	return tmpl, used, nil
+}
+
+// parseStatements compiles each of a tool's statements like parseTemplate,
+// returning the partials any of them use
+func parseStatements(tool ToolDefinition, lib *Library) ([]*template.Template, []string, error) {
+	var tmpls []*template.Template
+	names := map[string]bool{}
+	usedBy := map[string]bool{}
+	for i, stmt := range tool.Statements {
+		if stmt.Name == "" {
+			return nil, nil, fmt.Errorf("statement %d needs a name", i+1)
+		}
+		if names[stmt.Name] {
+			return nil, nil, fmt.Errorf("statement %s is defined twice", stmt.Name)
+		}
+		names[stmt.Name] = true
+		if strings.TrimSpace(stmt.SQL) == "" {
+			return nil, nil, fmt.Errorf("statement %s has no sql", stmt.Name)
+		}
+		tmpl, used, err := parseSQL(tool.Name+"."+stmt.Name, stmt.SQL, tool.Partials, lib)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid SQL in statement %s: %w", stmt.Name, err)
+		}
+		for _, name := range used {
+			usedBy[name] = true
+		}
+		tmpls = append(tmpls, tmpl)
+	}
+	used := make([]string, 0, len(usedBy))
+	for name := range usedBy {
+		used = append(used, name)
+	}
+	sort.Strings(used)
+	return tmpls, used, nil
+}
+
+// parseSQL compiles one SQL template named name with the shared and local
+// partials
+func parseSQL(name, text string, partials map[string]string, lib *Library) (*template.Template, []string, error) {
+	var root *template.Template
+	if lib != nil {
+		clone, err := lib.base.Clone()
+		if err != nil {
+			return nil, nil, err
+		}
+		root = clone.New(name)
+	} else {
+		root = template.New(name).Funcs(templateFuncs)
+	}
+	tmpl, err := root.Parse(text)
+	if err != nil {
+		return nil, nil, err
+	}
+	// Tool partials parse last so they replace same-named shared partials
+	// and the defaults of {{block}}s in the template
+	for pname, body := range partials {
+		if _, err := root.New(pname).Parse(body); err != nil {
+			return nil, nil, fmt.Errorf("invalid partial %s: %w", pname, err)
+		}
+	}
+	used, err := partialsUsed(tmpl)
+	if err != nil {
+		return nil, nil, err
+	}
+	return tmpl, used, nil
+}
+
+// partialsUsed follows {{template}} calls from tmpl and returns the partials
+// it reaches in sorted order, or an error naming one that is not defined
+func partialsUsed(tmpl *template.Template) ([]string, error) {
+	seen := map[string]bool{}
+	var visit func(name string, node parse.Node) error
+	visit = func(from string, node parse.Node) error {
+		switch n := node.(type) {
+		case *parse.ListNode:
+			if n == nil {
+				return nil
+			}
+			for _, child := range n.Nodes {
+				if err := visit(from, child); err != nil {
+					return err
+				}
+			}
+		case *parse.IfNode:
+			return visitBranch(visit, from, &n.BranchNode)
+		case *parse.RangeNode:
+			return visitBranch(visit, from, &n.BranchNode)
+		case *parse.WithNode:
+			return visitBranch(visit, from, &n.BranchNode)
+		case *parse.TemplateNode:
+			if seen[n.Name] {
+				return nil
+			}
+			called := tmpl.Lookup(n.Name)
+			if called == nil || called.Tree == nil {
+				return fmt.Errorf("%s calls undefined partial %q", from, n.Name)
+			}
+			seen[n.Name] = true
+			return visit(n.Name, called.Tree.Root)
+		}
+		return nil
+	}
+	if tmpl.Tree != nil {
+		if err := visit(tmpl.Name(), tmpl.Tree.Root); err != nil {
+			return nil, err
+		}
+	}
+	used := make([]string, 0, len(seen))
+	for name := range seen {
+		used = append(used, name)
+	}
+	sort.Strings(used)
+	return used, nil
+}
+
+func visitBranch(visit func(string, parse.Node) error, from string, b *parse.BranchNode) error {
+	if err := visit(from, b.List); err != nil {
+		return err
+	}
+	if b.ElseList != nil {
+		return visit(from, b.ElseList)
+	}
+	return nil
+}
+
+// dict builds a map from alternating keys and values so partials can take
+// arguments: {{template "eq_filter" dict "column" "status" "value" .status}}
+func dict(pairs ...any) (map[string]any, error) {
+	if len(pairs)%2 != 0 {
+		return nil, fmt.Errorf("dict needs an even number of arguments")
+	}
+	m := make(map[string]any, len(pairs)/2)
+	for i := 0; i < len(pairs); i += 2 {
+		key, ok := pairs[i].(string)
+		if !ok {
+			return nil, fmt.Errorf("dict key %v is not a string", pairs[i])
+		}
+		m[key] = pairs[i+1]
+	}
+	return m, nil
+}
@@ -0,0 +1,90 @@
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadToolsWithPartials(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, PartialsDir, "filters.sql"),
+		`{{define "eq_filter"}}{{if .value}}AND {{.column}} = '{{escape .value}}'{{end}}{{end}}`)
+	writeFile(t, filepath.Join(dir, "orders.yaml"), `name: orders
+sql_template: |
+  SELECT * FROM orders WHERE 1=1 {{template "eq_filter" dict "column" "status" "value" .status}} {{template "recent" .}}
+partials:
+  recent: "AND order_date > CURRENT_DATE - {{.days}}"
+parameters:
+  status: {type: string}
+  days: {type: integer, default: 7}
+`)
+	writeFile(t, filepath.Join(dir, "missing.yaml"), "name: missing\nsql_template: 'SELECT 1 {{template \"nope\" .}}'\n")
+
+	tools, loadErrors, err := LoadTools(dir)
+	if err != nil {
+		t.Fatalf("LoadTools: %v", err)
+	}
+	if len(tools) != 1 {
+		t.Fatalf("expected only orders to load (partials must not load as tools), got %+v", tools)
+	}
+	if len(loadErrors) != 1 || !strings.Contains(loadErrors[0].Error, `undefined partial "nope"`) {
+		t.Fatalf("expected an undefined partial error for missing.yaml, got %+v", loadErrors)
+	}
+
+	processor, err := NewSQLProcessor(tools[0])
+	if err != nil {
+		t.Fatalf("NewSQLProcessor: %v", err)
+	}
+	sql, err := processor.ProcessTemplate(map[string]any{"status": "o'pen"})
+	if err != nil {
+		t.Fatalf("ProcessTemplate: %v", err)
+	}
+	want := "SELECT * FROM orders WHERE 1=1 AND status = 'o''pen' AND order_date > CURRENT_DATE - 7"
+	if sql != want {
+		t.Fatalf("got %q, want %q", sql, want)
+	}
+	partials := strings.Join(processor.Partials(), ", ")
+	if partials != "eq_filter ("+filepath.Join(dir, PartialsDir, "filters.sql")+"), recent" {
+		t.Fatalf("unexpected partials: %s", partials)
+	}
+}
+
+func TestLoadLibraryDuplicate(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "a.sql"), `{{define "f"}}1{{end}}`)
+	writeFile(t, filepath.Join(dir, "b.sql"), `{{define "f"}}2{{end}}`)
+	writeFile(t, filepath.Join(dir, "c.tmpl"), `{{define "g"}}{{end`)
+
+	lib, loadErrors := LoadLibrary(dir)
+	if len(loadErrors) != 2 {
+		t.Fatalf("expected duplicate and parse errors, got %+v", loadErrors)
+	}
+	if lib.Source("f") != filepath.Join(dir, "a.sql") {
+		t.Fatalf("expected the first definition to win, got %q", lib.Source("f"))
+	}
+}
+
+func TestDict(t *testing.T) {
+	if _, err := dict("a"); err == nil {
+		t.Fatal("expected error for odd arguments")
+	}
+	if _, err := dict(1, 2); err == nil {
+		t.Fatal("expected error for a non-string key")
+	}
+	m, err := dict("column", "status", "value", 3)
+	if err != nil || m["column"] != "status" || m["value"] != 3 {
+		t.Fatalf("unexpected dict: %v, %v", m, err)
+	}
+}
@@ -0,0 +1,180 @@
+package tools
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"td_go_mcp/internal/jsonpath"
+)
+
+// PipelineType is the type: of YAML files holding a pipeline
+const PipelineType = "pipeline"
+
+// DefaultMaxItems caps how many times a step fans out when it sets no max_items
+const DefaultMaxItems = 100
+
+// PipelineDefinition is a tool whose steps call other YAML tools in order,
+// passing data between them with JSONPath expressions
+type PipelineDefinition struct {
+	Type           string               `yaml:"type" json:"type"`
+	Name           string               `yaml:"name" json:"name"`
+	Description    string               `yaml:"description" json:"description"`
+	Parameters     map[string]Parameter `yaml:"parameters,omitempty" json:"parameters,omitempty"`
+	Required       []string             `yaml:"required,omitempty" json:"required,omitempty"`
+	Steps          []PipelineStep       `yaml:"steps" json:"steps"`
+	AllowedRoles   []string             `yaml:"allowed_roles,omitempty" json:"allowed_roles,omitempty"`
+	AllowedUsers   []string             `yaml:"allowed_users,omitempty" json:"allowed_users,omitempty"`
+	RateLimit      string               `yaml:"rate_limit,omitempty" json:"rate_limit,omitempty"`
+	MaxConcurrency int                  `yaml:"max_concurrency,omitempty" json:"max_concurrency,omitempty"`
+	SourceFile     string               `yaml:"-" json:"source_file,omitempty"`
+
+	// Output projects the result from $.params and $.steps; without it the
+	// result is every step's output by name
+	Output any `yaml:"output,omitempty" json:"output,omitempty"`
+}
+
+// PipelineStep calls one tool. Args values that start with $ are paths into
+// the pipeline's data: $.params, $.steps.<earlier step> and, in a step with
+// for_each, $.item.
+type PipelineStep struct {
+	Name string         `yaml:"name" json:"name"`
+	Tool string         `yaml:"tool" json:"tool"`
+	Args map[string]any `yaml:"args,omitempty" json:"args,omitempty"`
+	// ForEach is a path to an array; the tool runs once per element and the
+	// step's output is the list of results
+	ForEach  string `yaml:"for_each,omitempty" json:"for_each,omitempty"`
+	MaxItems int    `yaml:"max_items,omitempty" json:"max_items,omitempty"`
+}
+
+// Tool returns the pipeline's input schema and call settings as a
+// ToolDefinition, for registering it alongside the YAML tools
+func (p *PipelineDefinition) Tool() ToolDefinition {
+	return ToolDefinition{
+		Name:           p.Name,
+		Description:    p.Description,
+		Parameters:     p.Parameters,
+		Required:       p.Required,
+		AllowedRoles:   p.AllowedRoles,
+		AllowedUsers:   p.AllowedUsers,
+		RateLimit:      p.RateLimit,
+		MaxConcurrency: p.MaxConcurrency,
+		SourceFile:     p.SourceFile,
+	}
+}
+
+// Limit returns the largest fan-out allowed for the step
+func (s PipelineStep) Limit() int {
+	if s.MaxItems > 0 {
+		return s.MaxItems
+	}
+	return DefaultMaxItems
+}
+
+// LoadPipelines loads every valid pipeline in dir, collecting per-file
+// failures as LoadErrors. Whether step tools exist is checked by the caller,
+// which knows the registered tools.
+func LoadPipelines(dir string) ([]PipelineDefinition, []LoadError, error) {
+	var pipelines []PipelineDefinition
+	var loadErrors []LoadError
+
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return pipelines, nil, nil // No tools directory, return empty
+	}
+
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && path != dir && strings.HasPrefix(info.Name(), "_") {
+			return filepath.SkipDir // _partials and other support directories
+		}
+		if info.IsDir() || !(strings.HasSuffix(path, ".yaml") || strings.HasSuffix(path, ".yml")) || yamlType(path) != PipelineType {
+			return nil
+		}
+		pipeline, err := loadPipelineFromFile(path)
+		if err != nil {
+			loadErrors = append(loadErrors, LoadError{File: path, Error: err.Error()})
+			return nil
+		}
+		pipelines = append(pipelines, pipeline)
+		return nil
+	})
+
+	return pipelines, loadErrors, err
+}
+
+func loadPipelineFromFile(path string) (PipelineDefinition, error) {
+	pipeline := PipelineDefinition{SourceFile: path}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return pipeline, err
+	}
+	if err := yaml.Unmarshal(data, &pipeline); err != nil {
+		return pipeline, err
+	}
+	if pipeline.Name == "" {
+		return pipeline, fmt.Errorf("name is required")
+	}
+	if len(pipeline.Steps) == 0 {
+		return pipeline, fmt.Errorf("steps are required")
+	}
+	tool := pipeline.Tool()
+	if err := tool.Limit().Validate(); err != nil {
+		return pipeline, err
+	}
+
+	earlier := map[string]bool{}
+	for i, step := range pipeline.Steps {
+		if step.Name == "" || step.Tool == "" {
+			return pipeline, fmt.Errorf("step %d needs a name and a tool", i+1)
+		}
+		if earlier[step.Name] {
+			return pipeline, fmt.Errorf("step %s is defined twice", step.Name)
+		}
+		if step.ForEach != "" {
+			if !jsonpath.IsExpr(step.ForEach) {
+				return pipeline, fmt.Errorf("step %s: for_each must be a path such as $.steps.users.rows", step.Name)
+			}
+			if err := checkPaths(step.ForEach, earlier, false); err != nil {
+				return pipeline, fmt.Errorf("step %s: %w", step.Name, err)
+			}
+		}
+		if err := checkPaths(step.Args, earlier, step.ForEach != ""); err != nil {
+			return pipeline, fmt.Errorf("step %s: %w", step.Name, err)
+		}
+		earlier[step.Name] = true
+	}
+	if err := checkPaths(pipeline.Output, earlier, false); err != nil {
+		return pipeline, fmt.Errorf("output: %w", err)
+	}
+	return pipeline, nil
+}
+
+// checkPaths parses the paths in tmpl and checks that they read params,
+// an earlier step, or the fan-out item when item is allowed
+func checkPaths(tmpl any, steps map[string]bool, item bool) error {
+	exprs, err := jsonpath.Paths(tmpl)
+	if err != nil {
+		return err
+	}
+	for _, expr := range exprs {
+		root := expr.Root(2)
+		switch {
+		case len(root) > 0 && root[0] == "params":
+		case len(root) > 0 && root[0] == "item" && item:
+		case len(root) == 2 && root[0] == "steps" && steps[root[1]]:
+		case len(root) == 2 && root[0] == "steps":
+			return fmt.Errorf("%s reads step %s, which does not run before it", expr, root[1])
+		case item:
+			return fmt.Errorf("%s must start with $.params, $.steps.<step> or $.item", expr)
+		default:
+			return fmt.Errorf("%s must start with $.params or $.steps.<step>", expr)
+		}
+	}
+	return nil
+}
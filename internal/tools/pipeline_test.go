@@ -0,0 +1,88 @@
+package tools
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadPipelines(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "tool.yaml"), "name: get_user\nsql_template: SELECT 1\n")
+	writeFile(t, filepath.Join(dir, "flow.yaml"), `type: pipeline
+name: user_flow
+parameters:
+  email:
+    type: string
+required: [email]
+rate_limit: 5/m
+steps:
+  - name: user
+    tool: get_user
+    args:
+      email: $.params.email
+  - name: sessions
+    tool: list_sessions
+    for_each: $.steps.user.rows
+    args:
+      user_id: $.item.user_id
+      limit: 10
+output:
+  user: $.steps.user.rows[0]
+`)
+
+	pipelines, loadErrors, err := LoadPipelines(dir)
+	if err != nil || len(loadErrors) != 0 {
+		t.Fatalf("LoadPipelines: %v %+v", err, loadErrors)
+	}
+	if len(pipelines) != 1 || len(pipelines[0].Steps) != 2 || pipelines[0].Steps[1].Limit() != DefaultMaxItems {
+		t.Fatalf("unexpected pipelines %+v", pipelines)
+	}
+	tool := pipelines[0].Tool()
+	if tool.Name != "user_flow" || tool.RateLimit != "5/m" || len(tool.Required) != 1 {
+		t.Fatalf("unexpected tool definition %+v", tool)
+	}
+
+	tools, _, err := LoadTools(dir)
+	if err != nil || len(tools) != 1 || tools[0].Name != "get_user" {
+		t.Fatalf("expected pipeline files to be skipped by LoadTools, got %+v %v", tools, err)
+	}
+}
+
+func TestPipelineErrors(t *testing.T) {
+	dir := t.TempDir()
+	for name, body := range map[string]string{
+		"nosteps.yaml": "type: pipeline\nname: a\n",
+		"later.yaml":   "type: pipeline\nname: b\nsteps:\n  - {name: one, tool: t, args: {x: $.steps.two.rows}}\n  - {name: two, tool: t}\n",
+		"item.yaml":    "type: pipeline\nname: c\nsteps:\n  - {name: one, tool: t, args: {x: $.item.id}}\n",
+		"root.yaml":    "type: pipeline\nname: d\nsteps:\n  - {name: one, tool: t}\noutput: $.other\n",
+		"dup.yaml":     "type: pipeline\nname: e\nsteps:\n  - {name: one, tool: t}\n  - {name: one, tool: t}\n",
+		"fanout.yaml":  "type: pipeline\nname: f\nsteps:\n  - {name: one, tool: t, for_each: rows}\n",
+	} {
+		writeFile(t, filepath.Join(dir, name), body)
+	}
+
+	pipelines, loadErrors, err := LoadPipelines(dir)
+	if err != nil {
+		t.Fatalf("LoadPipelines: %v", err)
+	}
+	if len(pipelines) != 0 {
+		t.Fatalf("expected every pipeline to fail, got %+v", pipelines)
+	}
+	got := map[string]string{}
+	for _, e := range loadErrors {
+		got[filepath.Base(e.File)] = e.Error
+	}
+	for file, msg := range map[string]string{
+		"nosteps.yaml": "steps are required",
+		"later.yaml":   "reads step two, which does not run before it",
+		"item.yaml":    "must start with $.params or $.steps.<step>",
+		"root.yaml":    "output: $.other",
+		"dup.yaml":     "step one is defined twice",
+		"fanout.yaml":  "for_each must be a path",
+	} {
+		if !strings.Contains(got[file], msg) {
+			t.Errorf("%s: expected error containing %q, got %q", file, msg, got[file])
+		}
+	}
+}
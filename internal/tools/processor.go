@@ -7,27 +7,97 @@ import (
 	"text/template"
 )
 
-// SQLProcessor handles SQL template processing and parameter substitution
+// SQLProcessor handles SQL template processing and parameter substitution.
+// The template is parsed once, so a processor is safe for concurrent use.
 type SQLProcessor struct {
-	tool ToolDefinition
+	tool     ToolDefinition
+	tmpl     *template.Template
+	stmts    []*template.Template
+	partials []string
 }
 
-// NewSQLProcessor creates a new SQL processor for the given tool
-func NewSQLProcessor(tool ToolDefinition) *SQLProcessor {
-	return &SQLProcessor{tool: tool}
+// NewSQLProcessor creates a new SQL processor for the given tool, failing
+// if its sql_template does not parse. Tools from the loader arrive compiled
+// with the shared partials; others can only use their own partials.
+func NewSQLProcessor(tool ToolDefinition) (*SQLProcessor, error) {
+	if tool.tmpl != nil || tool.stmts != nil {
+		return &SQLProcessor{tool: tool, tmpl: tool.tmpl, stmts: tool.stmts, partials: tool.partialsUsed}, nil
+	}
+	if len(tool.Statements) > 0 {
+		stmts, used, err := parseStatements(tool, nil)
+		if err != nil {
+			return nil, err
+		}
+		return &SQLProcessor{tool: tool, stmts: stmts, partials: used}, nil
+	}
+	tmpl, used, err := parseTemplate(tool, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &SQLProcessor{tool: tool, tmpl: tmpl, partials: used}, nil
+}
+
+// Partials lists the partials the template calls, with the file defining
+// each shared one, e.g. "date_filter (tools/_partials/filters.sql)"
+func (p *SQLProcessor) Partials() []string {
+	return p.partials
 }
 
-// ProcessTemplate fills the SQL template with provided parameters
+// ProcessTemplate fills the SQL template with provided parameters. For a
+// multi-statement tool it renders every statement, separated by ";", with
+// earlier results shown as <name>; use ProcessStatement to run them, as a
+// statement that reads a field of an earlier result fails to render here.
 func (p *SQLProcessor) ProcessTemplate(params map[string]any) (string, error) {
-	// Create template with custom functions
-	tmpl, err := template.New(p.tool.Name).Funcs(template.FuncMap{
-		"escape": escapeSQL,
-	}).Parse(p.tool.SQLTemplate)
-	if err != nil {
-		return "", fmt.Errorf("invalid SQL template: %w", err)
+	if p.stmts == nil {
+		return render(p.tmpl, p.values(params))
+	}
+	placeholders := make(map[string]any, len(p.tool.Statements))
+	rendered := make([]string, 0, len(p.stmts))
+	for i, stmt := range p.tool.Statements {
+		sql, err := p.ProcessStatement(i, params, placeholders)
+		if err != nil {
+			return "", fmt.Errorf("statement %s: %w", stmt.Name, err)
+		}
+		rendered = append(rendered, sql)
+		placeholders[stmt.Name] = "<" + stmt.Name + ">"
+	}
+	return strings.Join(rendered, ";\n\n"), nil
+}
+
+// Statements returns the statements of a multi-statement tool, in order
+func (p *SQLProcessor) Statements() []Statement {
+	return p.tool.Statements
+}
+
+// ProcessStatement renders statement i of a multi-statement tool. results
+// holds the earlier statements' results by name (see StatementValue) and is
+// available to the template as .results.
+func (p *SQLProcessor) ProcessStatement(i int, params map[string]any, results map[string]any) (string, error) {
+	if i < 0 || i >= len(p.stmts) {
+		return "", fmt.Errorf("tool %s has no statement %d", p.tool.Name, i)
 	}
+	values := p.values(params)
+	values["results"] = results
+	return render(p.stmts[i], values)
+}
 
-	// Merge parameters with defaults
+// StatementValue is how a statement's rows appear to later statements: the
+// value itself for one row of one column, the row for a single row, and the
+// rows otherwise
+func StatementValue(rows []map[string]any) any {
+	if len(rows) != 1 {
+		return rows
+	}
+	if len(rows[0]) == 1 {
+		for _, v := range rows[0] {
+			return v
+		}
+	}
+	return rows[0]
+}
+
+// values merges params over the parameter defaults, with fixed values last
+func (p *SQLProcessor) values(params map[string]any) map[string]any {
 	processedParams := make(map[string]any)
 
 	// Set defaults first
@@ -42,10 +112,17 @@ func (p *SQLProcessor) ProcessTemplate(params map[string]any) (string, error) {
 		processedParams[key] = value
 	}
 
+	// Values pinned by for_each expansion cannot be overridden
+	for key, value := range p.tool.Fixed {
+		processedParams[key] = value
+	}
+	return processedParams
+}
+
+func render(tmpl *template.Template, values map[string]any) (string, error) {
 	// Execute template
 	var buf bytes.Buffer
-	err = tmpl.Execute(&buf, processedParams)
-	if err != nil {
+	if err := tmpl.Execute(&buf, values); err != nil {
 		return "", fmt.Errorf("template execution failed: %w", err)
 	}
 
@@ -0,0 +1,83 @@
+package tools
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStatements(t *testing.T) {
+	processor, err := NewSQLProcessor(ToolDefinition{
+		Name:       "summary_and_detail",
+		Parameters: map[string]Parameter{"region": {Type: "string", Default: "eu"}},
+		Statements: []Statement{
+			{Name: "total", SQL: "SELECT COUNT(*) AS n FROM orders WHERE region = '{{.region}}'"},
+			{Name: "detail", SQL: "SELECT * FROM orders WHERE region = '{{.region}}' AND amount > {{.results.total}}"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewSQLProcessor: %v", err)
+	}
+
+	preview, err := processor.ProcessTemplate(nil)
+	if err != nil {
+		t.Fatalf("ProcessTemplate: %v", err)
+	}
+	want := "SELECT COUNT(*) AS n FROM orders WHERE region = 'eu';\n\nSELECT * FROM orders WHERE region = 'eu' AND amount > <total>"
+	if preview != want {
+		t.Fatalf("expected preview:\n%s\ngot:\n%s", want, preview)
+	}
+
+	results := map[string]any{"total": StatementValue([]map[string]any{{"n": 42}})}
+	sql, err := processor.ProcessStatement(1, map[string]any{"region": "us"}, results)
+	if err != nil {
+		t.Fatalf("ProcessStatement: %v", err)
+	}
+	if sql != "SELECT * FROM orders WHERE region = 'us' AND amount > 42" {
+		t.Fatalf("expected earlier scalar result in SQL, got %q", sql)
+	}
+	if _, err := processor.ProcessStatement(2, nil, nil); err == nil {
+		t.Fatal("expected an error for a statement out of range")
+	}
+}
+
+func TestStatementValue(t *testing.T) {
+	if v := StatementValue([]map[string]any{{"n": 1}}); v != 1 {
+		t.Errorf("expected a scalar for one row of one column, got %v", v)
+	}
+	if v, ok := StatementValue([]map[string]any{{"a": 1, "b": 2}}).(map[string]any); !ok || v["b"] != 2 {
+		t.Errorf("expected the row for a single row, got %v", v)
+	}
+	if v, ok := StatementValue(nil).([]map[string]any); !ok || len(v) != 0 {
+		t.Errorf("expected rows for an empty result, got %v", v)
+	}
+}
+
+func TestStatementsLoadErrors(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "both.yaml"), "name: both\nsql_template: SELECT 1\nstatements:\n  - {name: a, sql: SELECT 1}\n")
+	writeFile(t, filepath.Join(dir, "dup.yaml"), "name: dup\nstatements:\n  - {name: a, sql: SELECT 1}\n  - {name: a, sql: SELECT 2}\n")
+	writeFile(t, filepath.Join(dir, "cached.yaml"), "name: cached\ncache_ttl: 1m\nstatements:\n  - {name: a, sql: SELECT 1}\n")
+	writeFile(t, filepath.Join(dir, "ok.yaml"), "name: ok\ntransaction: true\nstatements:\n  - {name: a, sql: SELECT 1}\n  - {name: b, sql: 'SELECT {{.results.a}}'}\n")
+
+	tools, loadErrors, err := LoadTools(dir)
+	if err != nil {
+		t.Fatalf("LoadTools: %v", err)
+	}
+	if len(tools) != 1 || tools[0].Name != "ok" || !tools[0].Transaction {
+		t.Fatalf("expected only ok to load, got %+v", tools)
+	}
+	got := map[string]string{}
+	for _, e := range loadErrors {
+		got[filepath.Base(e.File)] = e.Error
+	}
+	for file, msg := range map[string]string{
+		"both.yaml":   "either sql_template or statements",
+		"dup.yaml":    "statement a is defined twice",
+		"cached.yaml": "cache_ttl is not supported",
+	} {
+		if !strings.Contains(got[file], msg) {
+			t.Errorf("%s: expected error containing %q, got %q", file, msg, got[file])
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestContextFromMeta(t *testing.T) {
+	if _, err := Setup(context.Background(), Config{Exporter: "off"}, nil); err != nil {
+		t.Fatalf("Setup failed: %v", err)
+	}
+
+	meta := &mcp.Meta{AdditionalFields: map[string]any{
+		"traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+	}}
+	ctx := ContextFromMeta(context.Background(), meta)
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsRemote() || sc.TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Fatalf("expected remote parent from traceparent, got %+v", sc)
+	}
+
+	if sc := trace.SpanContextFromContext(ContextFromMeta(context.Background(), nil)); sc.IsValid() {
+		t.Fatalf("expected no parent without _meta, got %+v", sc)
+	}
+}
+
+func TestStdoutExporter(t *testing.T) {
+	var buf bytes.Buffer
+	shutdown, err := Setup(context.Background(), Config{Exporter: "stdout"}, &buf)
+	if err != nil {
+		t.Fatalf("Setup failed: %v", err)
+	}
+	_, span := Tracer().Start(context.Background(), "tools/call count_records")
+	span.SetAttributes(AttrTool.String("count_records"))
+	span.End()
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown failed: %v", err)
+	}
+	if !strings.Contains(buf.String(), "tools/call count_records") {
+		t.Fatalf("expected span in stdout exporter output, got %q", buf.String())
+	}
+}
+
+func TestSetupRejectsUnknownExporter(t *testing.T) {
+	if _, err := Setup(context.Background(), Config{Exporter: "zipkin"}, nil); err == nil {
+		t.Fatal("expected error for unknown exporter")
+	}
+}
+
+func TestStatementHash(t *testing.T) {
+	a := StatementHash("SELECT 1")
+	if a != StatementHash("SELECT 1") || a == StatementHash("SELECT 2") {
+		t.Fatalf("statement hash should be stable and distinct, got %s", a)
+	}
+	if len(a) != 16 {
+		t.Fatalf("expected 16 hex chars, got %q", a)
+	}
+}
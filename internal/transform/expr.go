@@ -0,0 +1,322 @@
+package transform
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// expr is a parsed arithmetic expression over a row and the rows around it:
+//
+//	count * 100 / sum(count)
+//	round(amount / qty, 2)
+//
+// Identifiers read columns of the current row. sum, avg, min, max and count
+// aggregate a column over the row set (the group, inside group_by), and
+// round(x, digits) rounds.
+type expr interface {
+	eval(row map[string]any, rows []map[string]any) (float64, error)
+}
+
+type number float64
+
+type column string
+
+type unary struct{ x expr }
+
+type binary struct {
+	op   byte
+	l, r expr
+}
+
+type aggregate struct {
+	fn     string
+	column string
+}
+
+type roundCall struct {
+	x      expr
+	digits int
+}
+
+func (n number) eval(map[string]any, []map[string]any) (float64, error) { return float64(n), nil }
+
+func (c column) eval(row map[string]any, _ []map[string]any) (float64, error) {
+	v, ok := row[string(c)]
+	if !ok {
+		return 0, fmt.Errorf("no column %q", string(c))
+	}
+	f, ok := toFloat(v)
+	if !ok {
+		return 0, fmt.Errorf("column %q is not a number: %v", string(c), v)
+	}
+	return f, nil
+}
+
+func (u unary) eval(row map[string]any, rows []map[string]any) (float64, error) {
+	x, err := u.x.eval(row, rows)
+	return -x, err
+}
+
+func (b binary) eval(row map[string]any, rows []map[string]any) (float64, error) {
+	l, err := b.l.eval(row, rows)
+	if err != nil {
+		return 0, err
+	}
+	r, err := b.r.eval(row, rows)
+	if err != nil {
+		return 0, err
+	}
+	switch b.op {
+	case '+':
+		return l + r, nil
+	case '-':
+		return l - r, nil
+	case '*':
+		return l * r, nil
+	}
+	if r == 0 {
+		return 0, fmt.Errorf("division by zero")
+	}
+	return l / r, nil
+}
+
+func (a aggregate) eval(_ map[string]any, rows []map[string]any) (float64, error) {
+	if a.fn == "count" {
+		return float64(len(rows)), nil
+	}
+	var total float64
+	var n int
+	for _, row := range rows {
+		v, ok := row[a.column]
+		if !ok || v == nil {
+			continue
+		}
+		f, ok := toFloat(v)
+		if !ok {
+			return 0, fmt.Errorf("column %q is not a number: %v", a.column, v)
+		}
+		switch {
+		case n == 0:
+			total = f
+		case a.fn == "min":
+			total = math.Min(total, f)
+		case a.fn == "max":
+			total = math.Max(total, f)
+		default:
+			total += f
+		}
+		n++
+	}
+	if a.fn == "avg" && n > 0 {
+		return total / float64(n), nil
+	}
+	return total, nil
+}
+
+func (r roundCall) eval(row map[string]any, rows []map[string]any) (float64, error) {
+	x, err := r.x.eval(row, rows)
+	if err != nil {
+		return 0, err
+	}
+	scale := math.Pow(10, float64(r.digits))
+	return math.Round(x*scale) / scale, nil
+}
+
+// parseExpr parses src, reporting the position of the first error
+func parseExpr(src string) (expr, error) {
+	p := &exprParser{src: src}
+	p.next()
+	e, err := p.sum()
+	if err != nil {
+		return nil, fmt.Errorf("expression %q: %w", src, err)
+	}
+	if p.tok != "" {
+		return nil, fmt.Errorf("expression %q: unexpected %q", src, p.tok)
+	}
+	return e, nil
+}
+
+type exprParser struct {
+	src string
+	pos int
+	tok string
+}
+
+// next reads the next token: a number, an identifier or one punctuation
+// character. tok is "" at the end of input.
+func (p *exprParser) next() {
+	for p.pos < len(p.src) && unicode.IsSpace(rune(p.src[p.pos])) {
+		p.pos++
+	}
+	start := p.pos
+	if p.pos >= len(p.src) {
+		p.tok = ""
+		return
+	}
+	c := rune(p.src[p.pos])
+	switch {
+	case unicode.IsDigit(c) || c == '.':
+		for p.pos < len(p.src) && (unicode.IsDigit(rune(p.src[p.pos])) || p.src[p.pos] == '.') {
+			p.pos++
+		}
+	case unicode.IsLetter(c) || c == '_':
+		for p.pos < len(p.src) && (unicode.IsLetter(rune(p.src[p.pos])) || unicode.IsDigit(rune(p.src[p.pos])) || p.src[p.pos] == '_') {
+			p.pos++
+		}
+	default:
+		p.pos++
+	}
+	p.tok = p.src[start:p.pos]
+}
+
+func (p *exprParser) sum() (expr, error) {
+	l, err := p.product()
+	if err != nil {
+		return nil, err
+	}
+	for p.tok == "+" || p.tok == "-" {
+		op := p.tok[0]
+		p.next()
+		r, err := p.product()
+		if err != nil {
+			return nil, err
+		}
+		l = binary{op: op, l: l, r: r}
+	}
+	return l, nil
+}
+
+func (p *exprParser) product() (expr, error) {
+	l, err := p.factor()
+	if err != nil {
+		return nil, err
+	}
+	for p.tok == "*" || p.tok == "/" {
+		op := p.tok[0]
+		p.next()
+		r, err := p.factor()
+		if err != nil {
+			return nil, err
+		}
+		l = binary{op: op, l: l, r: r}
+	}
+	return l, nil
+}
+
+func (p *exprParser) factor() (expr, error) {
+	tok := p.tok
+	switch {
+	case tok == "":
+		return nil, fmt.Errorf("unexpected end")
+	case tok == "-":
+		p.next()
+		x, err := p.factor()
+		return unary{x: x}, err
+	case tok == "(":
+		p.next()
+		x, err := p.sum()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return x, nil
+	case unicode.IsDigit(rune(tok[0])) || tok[0] == '.':
+		f, err := strconv.ParseFloat(tok, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", tok)
+		}
+		p.next()
+		return number(f), nil
+	case unicode.IsLetter(rune(tok[0])) || tok[0] == '_':
+		p.next()
+		if p.tok != "(" {
+			return column(tok), nil
+		}
+		p.next()
+		return p.call(strings.ToLower(tok))
+	}
+	return nil, fmt.Errorf("unexpected %q", tok)
+}
+
+// call parses the arguments of fn after its opening parenthesis
+func (p *exprParser) call(fn string) (expr, error) {
+	switch fn {
+	case "sum", "avg", "min", "max", "count":
+		col := p.tok
+		if col == "" || !(unicode.IsLetter(rune(col[0])) || col[0] == '_') {
+			return nil, fmt.Errorf("%s needs a column name", fn)
+		}
+		p.next()
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return aggregate{fn: fn, column: col}, nil
+	case "round":
+		x, err := p.sum()
+		if err != nil {
+			return nil, err
+		}
+		digits := 0
+		if p.tok == "," {
+			p.next()
+			if digits, err = strconv.Atoi(p.tok); err != nil {
+				return nil, fmt.Errorf("round needs a whole number of digits, got %q", p.tok)
+			}
+			p.next()
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return roundCall{x: x, digits: digits}, nil
+	}
+	return nil, fmt.Errorf("unknown function %s", fn)
+}
+
+func (p *exprParser) expect(tok string) error {
+	if p.tok != tok {
+		if p.tok == "" {
+			return fmt.Errorf("expected %q at end", tok)
+		}
+		return fmt.Errorf("expected %q, got %q", tok, p.tok)
+	}
+	p.next()
+	return nil
+}
+
+// toFloat converts database and JSON numbers, and numeric strings such as
+// DECIMAL values returned as text
+func toFloat(v any) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int8:
+		return float64(n), true
+	case int16:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint8:
+		return float64(n), true
+	case uint16:
+		return float64(n), true
+	case uint32:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case float32:
+		return float64(n), true
+	case float64:
+		return n, true
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(n), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
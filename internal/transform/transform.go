@@ -0,0 +1,449 @@
+// Package transform reshapes a tool's result rows before they are returned:
+// renaming, selecting and computing columns, grouping, pivoting, sorting,
+// and reducing the result to a single value.
+package transform
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Transform is a parsed transform: section, a list of operations applied
+// in order. Each operation is a map with one key naming it:
+//
+//   - rename: {old: new}
+//   - select: [col, ...]
+//   - drop: [col, ...]
+//   - compute: {col: expression}
+//   - group_by: {by: [col, ...], aggregate: {col: expression}}
+//   - pivot: {index: col, columns: col, values: col}
+//   - sort: [col, -col, ...]
+//   - limit: n
+//   - scalar: col
+//
+// scalar must come last; it makes the result the column's value in the
+// first row instead of a list of rows, converted to the tool's return_type.
+type Transform struct {
+	ops        []op
+	scalar     string
+	returnType string
+}
+
+type op interface {
+	apply(rows []map[string]any) ([]map[string]any, error)
+}
+
+// Parse checks a transform: section and compiles its expressions. A scalar
+// result is converted to returnType when that is integer, number or
+// boolean. A nil or empty spec returns a nil *Transform, which leaves rows
+// unchanged.
+func Parse(spec []map[string]any, returnType string) (*Transform, error) {
+	if len(spec) == 0 {
+		return nil, nil
+	}
+	t := &Transform{returnType: returnType}
+	for i, step := range spec {
+		if len(step) != 1 {
+			return nil, fmt.Errorf("transform step %d must have exactly one operation", i+1)
+		}
+		for name, arg := range step {
+			if t.scalar != "" {
+				return nil, fmt.Errorf("transform step %d (%s): scalar must be the last step", i+1, name)
+			}
+			o, err := parseOp(name, arg)
+			if err != nil {
+				return nil, fmt.Errorf("transform step %d (%s): %w", i+1, name, err)
+			}
+			if s, ok := o.(scalarOp); ok {
+				t.scalar = string(s)
+				continue
+			}
+			t.ops = append(t.ops, o)
+		}
+	}
+	return t, nil
+}
+
+// Apply runs the transform on rows. The result is the transformed rows, or
+// a single value when the transform ends with scalar, which is an error if
+// more than one row is left.
+func (t *Transform) Apply(rows []map[string]any) (any, error) {
+	if t == nil {
+		return rows, nil
+	}
+	var err error
+	for _, o := range t.ops {
+		if rows, err = o.apply(rows); err != nil {
+			return nil, err
+		}
+	}
+	if t.scalar == "" {
+		return rows, nil
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+	if len(rows) > 1 {
+		return nil, fmt.Errorf("scalar: expected one row, got %d", len(rows))
+	}
+	v, ok := rows[0][t.scalar]
+	if !ok {
+		return nil, fmt.Errorf("scalar: no column %q", t.scalar)
+	}
+	return coerce(v, t.returnType)
+}
+
+// coerce converts a scalar to returnType. Drivers return DECIMAL values,
+// including Teradata's COUNT(*) in ANSI mode, as text.
+func coerce(v any, returnType string) (any, error) {
+	if v == nil {
+		return nil, nil
+	}
+	switch returnType {
+	case "integer":
+		f, ok := toFloat(v)
+		if !ok || f != math.Trunc(f) || math.Abs(f) > 1<<53 {
+			return nil, fmt.Errorf("scalar: %v is not an integer", v)
+		}
+		return int64(f), nil
+	case "number", "decimal", "float":
+		f, ok := toFloat(v)
+		if !ok {
+			return nil, fmt.Errorf("scalar: %v is not a number", v)
+		}
+		return f, nil
+	case "boolean":
+		switch b := v.(type) {
+		case bool:
+			return b, nil
+		case string:
+			if parsed, err := strconv.ParseBool(strings.TrimSpace(b)); err == nil {
+				return parsed, nil
+			}
+		}
+		if f, ok := toFloat(v); ok {
+			return f != 0, nil
+		}
+		return nil, fmt.Errorf("scalar: %v is not a boolean", v)
+	}
+	return v, nil
+}
+
+func parseOp(name string, arg any) (op, error) {
+	switch name {
+	case "rename":
+		m, err := stringMap(arg)
+		if err != nil {
+			return nil, err
+		}
+		return renameOp(m), nil
+	case "select", "drop":
+		cols, err := stringList(arg)
+		if err != nil {
+			return nil, err
+		}
+		return columnsOp{keep: name == "select", cols: cols}, nil
+	case "compute":
+		exprs, err := exprMap(arg)
+		if err != nil {
+			return nil, err
+		}
+		return computeOp(exprs), nil
+	case "group_by":
+		m, ok := arg.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("needs by and aggregate")
+		}
+		by, err := stringList(m["by"])
+		if err != nil || len(by) == 0 {
+			return nil, fmt.Errorf("by must name at least one column")
+		}
+		aggs, err := exprMap(m["aggregate"])
+		if err != nil {
+			return nil, fmt.Errorf("aggregate: %w", err)
+		}
+		return groupOp{by: by, aggs: aggs}, nil
+	case "pivot":
+		m, err := stringMap(arg)
+		if err != nil {
+			return nil, err
+		}
+		if m["index"] == "" || m["columns"] == "" || m["values"] == "" {
+			return nil, fmt.Errorf("needs index, columns and values")
+		}
+		return pivotOp{index: m["index"], columns: m["columns"], values: m["values"]}, nil
+	case "sort":
+		keys, err := stringList(arg)
+		if err != nil {
+			return nil, err
+		}
+		return sortOp(keys), nil
+	case "limit":
+		n, ok := arg.(int)
+		if !ok || n < 0 {
+			return nil, fmt.Errorf("needs a non-negative whole number, got %v", arg)
+		}
+		return limitOp(n), nil
+	case "scalar":
+		col, ok := arg.(string)
+		if !ok || col == "" {
+			return nil, fmt.Errorf("needs a column name")
+		}
+		return scalarOp(col), nil
+	}
+	return nil, fmt.Errorf("unknown operation")
+}
+
+type renameOp map[string]string
+
+func (o renameOp) apply(rows []map[string]any) ([]map[string]any, error) {
+	out := make([]map[string]any, 0, len(rows))
+	for _, row := range rows {
+		renamed := make(map[string]any, len(row))
+		for k, v := range row {
+			if to, ok := o[k]; ok {
+				k = to
+			}
+			renamed[k] = v
+		}
+		out = append(out, renamed)
+	}
+	return out, nil
+}
+
+type columnsOp struct {
+	keep bool
+	cols []string
+}
+
+func (o columnsOp) apply(rows []map[string]any) ([]map[string]any, error) {
+	listed := make(map[string]bool, len(o.cols))
+	for _, c := range o.cols {
+		listed[c] = true
+	}
+	out := make([]map[string]any, 0, len(rows))
+	for _, row := range rows {
+		kept := make(map[string]any, len(row))
+		for k, v := range row {
+			if listed[k] == o.keep {
+				kept[k] = v
+			}
+		}
+		out = append(out, kept)
+	}
+	return out, nil
+}
+
+type namedExpr struct {
+	name string
+	expr expr
+}
+
+// computeOp adds columns computed from each input row; aggregates range
+// over all input rows
+type computeOp []namedExpr
+
+func (o computeOp) apply(rows []map[string]any) ([]map[string]any, error) {
+	out := make([]map[string]any, 0, len(rows))
+	for _, row := range rows {
+		computed := make(map[string]any, len(row)+len(o))
+		for k, v := range row {
+			computed[k] = v
+		}
+		for _, ne := range o {
+			v, err := ne.expr.eval(row, rows)
+			if err != nil {
+				return nil, fmt.Errorf("compute %s: %w", ne.name, err)
+			}
+			computed[ne.name] = v
+		}
+		out = append(out, computed)
+	}
+	return out, nil
+}
+
+// groupOp returns one row per distinct combination of the by columns, in
+// order of first appearance, with each aggregate evaluated over the group
+type groupOp struct {
+	by   []string
+	aggs []namedExpr
+}
+
+func (o groupOp) apply(rows []map[string]any) ([]map[string]any, error) {
+	var order []string
+	groups := map[string][]map[string]any{}
+	for _, row := range rows {
+		parts := make([]string, len(o.by))
+		for i, col := range o.by {
+			parts[i] = fmt.Sprint(row[col])
+		}
+		key := strings.Join(parts, "\x00")
+		if _, ok := groups[key]; !ok {
+			order = append(order, key)
+		}
+		groups[key] = append(groups[key], row)
+	}
+	out := make([]map[string]any, 0, len(order))
+	for _, key := range order {
+		group := groups[key]
+		row := make(map[string]any, len(o.by)+len(o.aggs))
+		for _, col := range o.by {
+			row[col] = group[0][col]
+		}
+		for _, ne := range o.aggs {
+			v, err := ne.expr.eval(group[0], group)
+			if err != nil {
+				return nil, fmt.Errorf("aggregate %s: %w", ne.name, err)
+			}
+			row[ne.name] = v
+		}
+		out = append(out, row)
+	}
+	return out, nil
+}
+
+// pivotOp turns the distinct values of columns into columns holding values,
+// one row per distinct index value
+type pivotOp struct {
+	index, columns, values string
+}
+
+func (o pivotOp) apply(rows []map[string]any) ([]map[string]any, error) {
+	var out []map[string]any
+	byIndex := map[string]map[string]any{}
+	for _, row := range rows {
+		key := fmt.Sprint(row[o.index])
+		pivoted, ok := byIndex[key]
+		if !ok {
+			pivoted = map[string]any{o.index: row[o.index]}
+			byIndex[key] = pivoted
+			out = append(out, pivoted)
+		}
+		col := fmt.Sprint(row[o.columns])
+		if col == o.index {
+			return nil, fmt.Errorf("pivot column value %q clashes with the index column", col)
+		}
+		pivoted[col] = row[o.values]
+	}
+	if out == nil {
+		out = []map[string]any{}
+	}
+	return out, nil
+}
+
+// sortOp orders rows by each key in turn; "-col" sorts descending. Numbers
+// compare numerically, other values as text, and missing values first.
+type sortOp []string
+
+func (o sortOp) apply(rows []map[string]any) ([]map[string]any, error) {
+	out := append([]map[string]any(nil), rows...)
+	sort.SliceStable(out, func(i, j int) bool {
+		for _, key := range o {
+			col, desc := strings.TrimPrefix(key, "-"), strings.HasPrefix(key, "-")
+			c := compare(out[i][col], out[j][col])
+			if c == 0 {
+				continue
+			}
+			return (c < 0) != desc
+		}
+		return false
+	})
+	return out, nil
+}
+
+func compare(a, b any) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return -1
+	case b == nil:
+		return 1
+	}
+	fa, okA := toFloat(a)
+	fb, okB := toFloat(b)
+	if okA && okB {
+		switch {
+		case fa < fb:
+			return -1
+		case fa > fb:
+			return 1
+		}
+		return 0
+	}
+	return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+}
+
+type limitOp int
+
+func (o limitOp) apply(rows []map[string]any) ([]map[string]any, error) {
+	if len(rows) > int(o) {
+		rows = rows[:o]
+	}
+	return rows, nil
+}
+
+// scalarOp is recorded by Parse rather than applied
+type scalarOp string
+
+func (o scalarOp) apply(rows []map[string]any) ([]map[string]any, error) { return rows, nil }
+
+func stringMap(arg any) (map[string]string, error) {
+	m, ok := arg.(map[string]any)
+	if !ok || len(m) == 0 {
+		return nil, fmt.Errorf("needs a map of names")
+	}
+	out := make(map[string]string, len(m))
+	for k, v := range m {
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s: expected a name, got %v", k, v)
+		}
+		out[k] = s
+	}
+	return out, nil
+}
+
+func stringList(arg any) ([]string, error) {
+	if s, ok := arg.(string); ok && s != "" {
+		return []string{s}, nil
+	}
+	list, ok := arg.([]any)
+	if !ok || len(list) == 0 {
+		return nil, fmt.Errorf("needs a list of column names")
+	}
+	out := make([]string, 0, len(list))
+	for _, v := range list {
+		s, ok := v.(string)
+		if !ok || s == "" {
+			return nil, fmt.Errorf("expected a column name, got %v", v)
+		}
+		out = append(out, s)
+	}
+	return out, nil
+}
+
+// exprMap parses a map of column names to expressions, in name order
+func exprMap(arg any) ([]namedExpr, error) {
+	m, err := stringMap(arg)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	out := make([]namedExpr, 0, len(names))
+	for _, name := range names {
+		e, err := parseExpr(m[name])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		out = append(out, namedExpr{name: name, expr: e})
+	}
+	return out, nil
+}
@@ -0,0 +1,181 @@
+package transform
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func parse(t *testing.T, src string) *Transform {
+	t.Helper()
+	return parseAs(t, src, "")
+}
+
+func parseAs(t *testing.T, src, returnType string) *Transform {
+	t.Helper()
+	var spec []map[string]any
+	if err := yaml.Unmarshal([]byte(src), &spec); err != nil {
+		t.Fatalf("yaml: %v", err)
+	}
+	tr, err := Parse(spec, returnType)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	return tr
+}
+
+func TestScalar(t *testing.T) {
+	tr := parse(t, "- scalar: record_count\n")
+	got, err := tr.Apply([]map[string]any{{"record_count": 1250}})
+	if err != nil || got != 1250 {
+		t.Fatalf("expected 1250, got %v %v", got, err)
+	}
+	if got, err := tr.Apply(nil); err != nil || got != nil {
+		t.Fatalf("expected nil for no rows, got %v %v", got, err)
+	}
+	if _, err := tr.Apply([]map[string]any{{"other": 1}}); err == nil {
+		t.Fatalf("expected an error for a missing column")
+	}
+	if _, err := tr.Apply([]map[string]any{{"record_count": 1}, {"record_count": 2}}); err == nil || !strings.Contains(err.Error(), "one row") {
+		t.Fatalf("expected an error for several rows, got %v", err)
+	}
+}
+
+func TestScalarReturnType(t *testing.T) {
+	cases := []struct {
+		returnType string
+		cell       any
+		want       any
+	}{
+		// ODBC returns DECIMAL, e.g. COUNT(*) in ANSI mode, as text
+		{"integer", "1250", int64(1250)},
+		{"integer", "1250.00", int64(1250)},
+		{"integer", int32(7), int64(7)},
+		{"number", "12.5", 12.5},
+		{"boolean", "true", true},
+		{"boolean", int64(0), false},
+		{"string", "1250", "1250"},
+		{"", "1250", "1250"},
+		{"integer", nil, nil},
+	}
+	for _, tc := range cases {
+		got, err := parseAs(t, "- scalar: v\n", tc.returnType).Apply([]map[string]any{{"v": tc.cell}})
+		if err != nil || got != tc.want {
+			t.Errorf("%s from %#v: expected %#v, got %#v %v", tc.returnType, tc.cell, tc.want, got, err)
+		}
+	}
+	if _, err := parseAs(t, "- scalar: v\n", "integer").Apply([]map[string]any{{"v": "12.5"}}); err == nil || !strings.Contains(err.Error(), "not an integer") {
+		t.Fatalf("expected a fractional value to be rejected as an integer, got %v", err)
+	}
+}
+
+func TestNilTransform(t *testing.T) {
+	tr, err := Parse(nil, "")
+	if err != nil || tr != nil {
+		t.Fatalf("expected nil transform, got %v %v", tr, err)
+	}
+	rows := []map[string]any{{"a": 1}}
+	if got, _ := tr.Apply(rows); !reflect.DeepEqual(got, rows) {
+		t.Fatalf("expected rows unchanged, got %v", got)
+	}
+}
+
+func TestRowOperations(t *testing.T) {
+	tr := parse(t, `
+- rename: {cnt: sessions}
+- compute:
+    pct: round(sessions * 100 / sum(sessions), 1)
+- drop: [internal]
+- sort: [-sessions]
+- limit: 2
+`)
+	got, err := tr.Apply([]map[string]any{
+		{"type": "a", "cnt": 1, "internal": true},
+		{"type": "b", "cnt": "6", "internal": true},
+		{"type": "c", "cnt": int64(3), "internal": true},
+	})
+	if err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	want := []map[string]any{
+		{"type": "b", "sessions": "6", "pct": 60.0},
+		{"type": "c", "sessions": int64(3), "pct": 30.0},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+}
+
+func TestGroupAndPivot(t *testing.T) {
+	rows := []map[string]any{
+		{"region": "eu", "status": "open", "n": 2},
+		{"region": "us", "status": "open", "n": 5},
+		{"region": "eu", "status": "closed", "n": 4},
+		{"region": "eu", "status": "open", "n": 1},
+	}
+	grouped, err := parse(t, `
+- group_by:
+    by: region
+    aggregate: {total: sum(n), orders: count(n), biggest: max(n)}
+`).Apply(rows)
+	if err != nil {
+		t.Fatalf("group_by: %v", err)
+	}
+	want := []map[string]any{
+		{"region": "eu", "total": 7.0, "orders": 3.0, "biggest": 4.0},
+		{"region": "us", "total": 5.0, "orders": 1.0, "biggest": 5.0},
+	}
+	if !reflect.DeepEqual(grouped, want) {
+		t.Fatalf("expected %v, got %v", want, grouped)
+	}
+
+	pivoted, err := parse(t, `
+- group_by: {by: [region, status], aggregate: {n: sum(n)}}
+- pivot: {index: region, columns: status, values: n}
+- select: [region, open]
+`).Apply(rows)
+	if err != nil {
+		t.Fatalf("pivot: %v", err)
+	}
+	want = []map[string]any{
+		{"region": "eu", "open": 3.0},
+		{"region": "us", "open": 5.0},
+	}
+	if !reflect.DeepEqual(pivoted, want) {
+		t.Fatalf("expected %v, got %v", want, pivoted)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for src, msg := range map[string]string{
+		"- {rename: {a: b}, drop: [c]}\n":        "exactly one operation",
+		"- unknown: 1\n":                         "unknown operation",
+		"- scalar: a\n- limit: 1\n":              "scalar must be the last step",
+		"- compute: {x: 'a +'}\n":                "unexpected end",
+		"- compute: {x: 'median(a)'}\n":          "unknown function median",
+		"- compute: {x: 'round(a, b)'}\n":        "whole number of digits",
+		"- group_by: {aggregate: {n: sum(n)}}\n": "by must name at least one column",
+		"- pivot: {index: a, columns: b}\n":      "needs index, columns and values",
+		"- limit: -1\n":                          "non-negative",
+	} {
+		var spec []map[string]any
+		if err := yaml.Unmarshal([]byte(src), &spec); err != nil {
+			t.Fatalf("yaml %q: %v", src, err)
+		}
+		_, err := Parse(spec, "")
+		if err == nil || !strings.Contains(err.Error(), msg) {
+			t.Errorf("%q: expected error containing %q, got %v", src, msg, err)
+		}
+	}
+}
+
+func TestApplyErrors(t *testing.T) {
+	if _, err := parse(t, "- compute: {x: a / b}\n").Apply([]map[string]any{{"a": 1, "b": 0}}); err == nil || !strings.Contains(err.Error(), "division by zero") {
+		t.Fatalf("expected division by zero, got %v", err)
+	}
+	if _, err := parse(t, "- compute: {x: a * 2}\n").Apply([]map[string]any{{"a": "abc"}}); err == nil || !strings.Contains(err.Error(), "not a number") {
+		t.Fatalf("expected a not-a-number error, got %v", err)
+	}
+}
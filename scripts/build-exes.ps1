@@ -1,4 +1,4 @@
-# Build Windows .exe binaries for MCP stdio server and HTTP server
+# Build the Windows .exe binary for the MCP server (stdio or HTTP, with admin endpoint)
 param()
 
 $ErrorActionPreference = 'Stop'
@@ -11,8 +11,5 @@ if (-not (Test-Path $bin)) { New-Item -ItemType Directory -Path $bin | Out-Null
 Write-Host "Building mcp.exe..." -ForegroundColor Cyan
 & go build -o (Join-Path $bin 'mcp.exe') './cmd/mcp'
 
-Write-Host "Building server.exe..." -ForegroundColor Cyan
-& go build -o (Join-Path $bin 'server.exe') './cmd/server'
-
 Write-Host "Built binaries:" -ForegroundColor Green
 Get-ChildItem $bin | Select-Object Name,Length,LastWriteTime | Format-Table -AutoSize
@@ -9,7 +9,16 @@ try {
     Write-Host "Health Check: $($health.StatusCode) - $($health.Content)" -ForegroundColor Cyan
 } catch {
     Write-Host "Health Check Failed: $($_.Exception.Message)" -ForegroundColor Red
-    Write-Host "Make sure HTTP server is running: go run ./cmd/server" -ForegroundColor Yellow
+    Write-Host "Make sure HTTP server is running: go run ./cmd/mcp --admin-listen :8080" -ForegroundColor Yellow
+}
+
+# Test readiness endpoint (503 names the failing component)
+try {
+    $ready = Invoke-WebRequest -Uri "http://localhost:8080/readyz" -UseBasicParsing -SkipHttpErrorCheck
+    $readyJson = $ready.Content | ConvertFrom-Json
+    Write-Host "Readiness: $($ready.StatusCode) - $($readyJson.status) $($readyJson.failing -join ', ')" -ForegroundColor Cyan
+} catch {
+    Write-Host "Readiness Check Failed: $($_.Exception.Message)" -ForegroundColor Red
 }
 
 # Test root endpoint
@@ -17,7 +26,10 @@ try {
     $root = Invoke-WebRequest -Uri "http://localhost:8080/" -UseBasicParsing
     Write-Host "Root Endpoint: $($root.StatusCode)" -ForegroundColor Cyan
     $rootJson = $root.Content | ConvertFrom-Json
-    Write-Host "Server Info: $($rootJson.name) v$($rootJson.version) - $($rootJson.tools_loaded) tools loaded" -ForegroundColor Cyan
+    Write-Host "Server Info: $($rootJson.name) v$($rootJson.version) - $($rootJson.tools_loaded) tools loaded, status $($rootJson.status)" -ForegroundColor Cyan
+    foreach ($loadError in $rootJson.load_errors) {
+        Write-Host "  Load error: $($loadError.file): $($loadError.error)" -ForegroundColor Yellow
+    }
 } catch {
     Write-Host "Root Endpoint Failed: $($_.Exception.Message)" -ForegroundColor Red
 }
@@ -0,0 +1,16 @@
+{{/*
+  Shared WHERE-clause fragments. Call them from any sql_template:
+
+    {{template "date_filter" .}}
+    {{template "eq_filter" dict "column" "status" "value" .status}}
+*/}}
+
+{{/* date_filter restricts created_at to the day in .date_filter (YYYY-MM-DD), if set */}}
+{{define "date_filter"}}{{if .date_filter}}
+AND DATE(created_at) = '{{escape .date_filter}}'
+{{end}}{{end}}
+
+{{/* eq_filter adds "AND <column> = '<value>'" when value is set */}}
+{{define "eq_filter"}}{{if .value}}
+AND {{.column}} = '{{escape (printf "%v" .value)}}'
+{{end}}{{end}}